@@ -0,0 +1,111 @@
+package goose
+
+import "errors"
+
+// Permission is an operation users can be allowed to do in a bucket
+type Permission string
+
+const (
+	// PermRead allows reading the bucket, and listing and downloading its objects
+	PermRead Permission = "read"
+	// PermWrite allows uploading objects, updating their metadata and restoring versions
+	PermWrite Permission = "write"
+	// PermDelete allows deleting objects and their versions
+	PermDelete Permission = "delete"
+	// PermAdmin allows everything, including changing the bucket settings and ACL and deleting the bucket
+	PermAdmin Permission = "admin"
+)
+
+// RoleAdmin is the role of the users allowed to do everything in every bucket
+const RoleAdmin = "admin"
+
+// Visibility tells who can download the objects of a bucket from the file server
+type Visibility string
+
+const (
+	// VisibilityPublic buckets are served to anyone. It is the default
+	VisibilityPublic Visibility = "public"
+	// VisibilityAuthenticated buckets are served to any authenticated user allowed to access them
+	VisibilityAuthenticated Visibility = "authenticated"
+	// VisibilityPrivate buckets are only served to the users with read permission
+	VisibilityPrivate Visibility = "private"
+)
+
+// Valid tells whether the visibility is one of the known ones, or empty
+func (v Visibility) Valid() bool {
+	switch v {
+	case "", VisibilityPublic, VisibilityAuthenticated, VisibilityPrivate:
+		return true
+	}
+	return false
+}
+
+// ErrInvalidPermission is returned when an ACL has a permission other than read, write, delete or admin
+var ErrInvalidPermission = errors.New("invalid permission, use read, write, delete or admin")
+
+// ErrInvalidGrant is returned when an ACL grant is not given to exactly one user, role or group
+var ErrInvalidGrant = errors.New("invalid grant, set one of the user, the role or the group")
+
+// ACL controls the access to a bucket. The owner is allowed to do everything, and other users are allowed to do
+// what is granted to them or to any of their roles or groups
+type ACL struct {
+	// Owner is the subject of the user who owns the bucket
+	Owner  string  `bson:"owner,omitempty" json:"owner"`
+	Grants []Grant `bson:"grants,omitempty" json:"grants"`
+}
+
+// Grant gives permissions to a user, or to every user with a role or in a group
+type Grant struct {
+	User        string       `bson:"user,omitempty" json:"user,omitempty"`
+	Role        string       `bson:"role,omitempty" json:"role,omitempty"`
+	Group       string       `bson:"group,omitempty" json:"group,omitempty"`
+	Permissions []Permission `bson:"permissions" json:"permissions"`
+}
+
+// Validate checks that every grant is given to a user, role or group, with known permissions
+func (acl ACL) Validate() error {
+	for _, g := range acl.Grants {
+		set := 0
+		for _, principal := range []string{g.User, g.Role, g.Group} {
+			if principal != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			return ErrInvalidGrant
+		}
+		for _, p := range g.Permissions {
+			switch p {
+			case PermRead, PermWrite, PermDelete, PermAdmin:
+			default:
+				return ErrInvalidPermission
+			}
+		}
+	}
+	return nil
+}
+
+// Allows tells whether the ACL gives the permission to the user
+func (acl ACL) Allows(u *User, perm Permission) bool {
+	if u.Subject != "" && u.Subject == acl.Owner {
+		return true
+	}
+	for _, g := range acl.Grants {
+		if (g.User != "" && g.User == u.Subject) || (g.Role != "" && u.HasRole(g.Role)) ||
+			(g.Group != "" && u.InGroup(g.Group)) {
+			if g.allows(perm) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (g Grant) allows(perm Permission) bool {
+	for _, p := range g.Permissions {
+		if p == perm || p == PermAdmin {
+			return true
+		}
+	}
+	return false
+}
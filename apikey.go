@@ -0,0 +1,191 @@
+package goose
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func init() {
+	RegisterDBInitTask(func(db *DBConn) error { return NewAPIKeyRepo(db).Init() })
+}
+
+// Scopes limit the operations allowed to API keys
+const (
+	// ScopeBucketRead allows retrieving and listing buckets
+	ScopeBucketRead = "bucket:read"
+	// ScopeBucketWrite allows creating, updating and deleting buckets, and changing their ACL
+	ScopeBucketWrite = "bucket:write"
+	// ScopeObjectRead allows retrieving, listing and downloading objects
+	ScopeObjectRead = "object:read"
+	// ScopeObjectWrite allows uploading objects, updating their metadata and restoring versions
+	ScopeObjectWrite = "object:write"
+	// ScopeObjectDelete allows deleting objects and their versions
+	ScopeObjectDelete = "object:delete"
+	// ScopeAdmin allows the administration endpoints, like the API key management
+	ScopeAdmin = "admin"
+)
+
+const apiKeyPrefix = "gk_"
+
+var (
+	// ErrInvalidScope is returned when creating an API key with an unknown scope
+	ErrInvalidScope = errors.New("invalid scope")
+	// ErrInvalidAPIKey is returned when an API key is malformed, unknown, expired or its secret does not match
+	ErrInvalidAPIKey = errors.New("invalid API key")
+)
+
+// ValidScope tells whether the scope is one of the known ones
+func ValidScope(scope string) bool {
+	switch scope {
+	case ScopeBucketRead, ScopeBucketWrite, ScopeObjectRead, ScopeObjectWrite, ScopeObjectDelete, ScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// APIKey is a long-lived credential for services, acting on behalf of its owner with a limited set of scopes.
+// Only a hash of the secret is stored, the secret is given once when the key is created
+type APIKey struct {
+	ID    bson.ObjectId `bson:"_id" json:"id"`
+	Name  string        `bson:"name" json:"name"`
+	Owner string        `bson:"owner" json:"owner"`
+	// Roles are given to the users authenticated with the key
+	Roles  []string `bson:"roles,omitempty" json:"roles,omitempty"`
+	Scopes []string `bson:"scopes" json:"scopes"`
+	// Buckets are the names or IDs of the buckets the key can access. Empty means any bucket
+	Buckets []string `bson:"buckets,omitempty" json:"buckets,omitempty"`
+	// Expires is the time after which the key is no longer valid, nil if it never expires
+	Expires  *time.Time `bson:"expires,omitempty" json:"expires,omitempty"`
+	LastUsed *time.Time `bson:"lastUsed,omitempty" json:"lastUsed,omitempty"`
+	Created  time.Time  `bson:"created" json:"created"`
+	Hash     string     `bson:"hash" json:"-"`
+}
+
+// NewSecret generates the secret of the key, storing its hash, and returns the full key to hand out to its user
+func (k *APIKey) NewSecret() (string, error) {
+	if k.ID.Hex() == "" {
+		k.ID = bson.NewObjectId()
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(b)
+	k.Hash = hashSecret(secret)
+	return apiKeyPrefix + k.ID.Hex() + "_" + secret, nil
+}
+
+// ParseAPIKey splits a key handed out by NewSecret into the key ID and its secret
+func ParseAPIKey(key string) (ID, secret string, err error) {
+	parts := strings.SplitN(strings.TrimPrefix(key, apiKeyPrefix), "_", 2)
+	if !strings.HasPrefix(key, apiKeyPrefix) || len(parts) != 2 || !ValidObjectID(parts[0]) {
+		return "", "", ErrInvalidAPIKey
+	}
+	return parts[0], parts[1], nil
+}
+
+// Check tells whether the secret matches the key, and the key has not expired
+func (k *APIKey) Check(secret string) bool {
+	if k.Expires != nil && time.Now().After(*k.Expires) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(k.Hash)) == 1
+}
+
+// User returns the user authenticated with the key
+func (k *APIKey) User() *User {
+	scopes := k.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return &User{Subject: k.Owner, Roles: k.Roles, Buckets: k.Buckets, Scopes: scopes}
+}
+
+// hashSecret returns the hex encoded SHA-256 of the secret. The secrets are random, so a slow hash is not needed
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyStore is implemented by the storage drivers that persist API keys
+type APIKeyStore interface {
+	// Insert stores a new API key
+	Insert(k *APIKey) error
+	// FindId returns the API key with the given ID
+	FindId(ID string) (*APIKey, error)
+	// Find lists the API keys of an owner, or every key if the owner is empty, newest first
+	Find(owner string) ([]*APIKey, error)
+	// Touch sets the last time the API key with the given ID was used
+	Touch(ID string, lastUsed time.Time) error
+	// DeleteId removes the API key with the given ID, revoking it
+	DeleteId(ID string) error
+}
+
+type apiKeyRepo struct {
+	db  *DBConn
+	col *mgo.Collection
+}
+
+func NewAPIKeyRepo(db *DBConn) *apiKeyRepo {
+	return &apiKeyRepo{db: db, col: db.C("apikeys")}
+}
+
+func (r *apiKeyRepo) Init() error {
+	return r.col.EnsureIndex(mgo.Index{Key: []string{"owner", "-created"}})
+}
+
+func (r *apiKeyRepo) Insert(k *APIKey) error {
+	if k.ID.Hex() == "" {
+		k.ID = bson.NewObjectId()
+	}
+	if k.Created.IsZero() {
+		k.Created = time.Now()
+	}
+	err := r.col.Insert(k)
+	if mgo.IsDup(err) {
+		return ErrDuplicateKey
+	}
+	return err
+}
+
+func (r *apiKeyRepo) FindId(ID string) (*APIKey, error) {
+	k := &APIKey{}
+	if err := checkObjectId(ID); err != nil {
+		return k, err
+	}
+	err := r.col.FindId(bson.ObjectIdHex(ID)).One(k)
+	return k, err
+}
+
+func (r *apiKeyRepo) Find(owner string) ([]*APIKey, error) {
+	where := bson.M{}
+	if owner != "" {
+		where["owner"] = owner
+	}
+	keys := []*APIKey{}
+	err := r.col.Find(where).Sort("-created").All(&keys)
+	return keys, err
+}
+
+func (r *apiKeyRepo) Touch(ID string, lastUsed time.Time) error {
+	if err := checkObjectId(ID); err != nil {
+		return err
+	}
+	return r.col.UpdateId(bson.ObjectIdHex(ID), bson.M{"$set": bson.M{"lastUsed": lastUsed}})
+}
+
+func (r *apiKeyRepo) DeleteId(ID string) error {
+	if err := checkObjectId(ID); err != nil {
+		return err
+	}
+	return r.col.RemoveId(bson.ObjectIdHex(ID))
+}
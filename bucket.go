@@ -1,6 +1,10 @@
 package goose
 
 import (
+	"encoding/base64"
+	"regexp"
+	"strings"
+
 	"github.com/syb-devs/gotools/time"
 	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
@@ -11,12 +15,78 @@ func init() {
 }
 
 type Bucket struct {
-	ID          bson.ObjectId `bson:"_id" json:"id"`
-	Name        string        `bson:"name" json:"name"`
-	Collection  string        `json:"collection"`
-	Objects     int           `json:"collection"`
-	Size        int           `json:"collection"`
-	time.Stamps `bson:",inline"`
+	ID         bson.ObjectId `bson:"_id" json:"id"`
+	Name       string        `bson:"name" json:"name"`
+	Versioning bool          `bson:"versioning" json:"versioning"`
+	Quota      BucketQuota   `bson:"quota" json:"quota"`
+	Visibility Visibility    `bson:"visibility,omitempty" json:"visibility,omitempty"`
+	// CacheControl is the default Cache-Control header sent when serving the objects of the bucket
+	CacheControl string `bson:"cacheControl,omitempty" json:"cacheControl,omitempty"`
+	ACL          ACL    `bson:"acl" json:"-"`
+	Collection   string `json:"collection"`
+	Objects      int    `bson:"objects" json:"objects"`
+	Size         int64  `bson:"size" json:"size"`
+	time.Stamps  `bson:",inline"`
+}
+
+// BucketListOptions controls the buckets returned by a listing operation. Buckets are listed by name
+type BucketListOptions struct {
+	// Limit is the maximum number of buckets returned, 0 means no limit
+	Limit int
+	// Prefix restricts the listing to the buckets whose name starts with it
+	Prefix string
+	// Cursor resumes a previous listing, and must be the Next token of its last page
+	Cursor string
+	// Filter, if set, restricts the listing to the buckets for which it returns true
+	Filter func(b *Bucket) bool
+}
+
+// after decodes the listing cursor, returning the name of the last bucket listed
+func (o BucketListOptions) after() (string, error) {
+	if o.Cursor == "" {
+		return "", nil
+	}
+	name, err := base64.RawURLEncoding.DecodeString(o.Cursor)
+	if err != nil || len(name) == 0 {
+		return "", ErrInvalidCursor
+	}
+	return string(name), nil
+}
+
+// match reports whether the bucket passes the listing filters, except for the cursor
+func (o BucketListOptions) match(b *Bucket) bool {
+	return strings.HasPrefix(b.Name, o.Prefix) && (o.Filter == nil || o.Filter(b))
+}
+
+// BucketList holds the buckets returned by a listing operation
+type BucketList struct {
+	buckets []*Bucket
+	next    string
+}
+
+// Buckets returns the buckets in the list
+func (bl *BucketList) Buckets() []*Bucket {
+	if bl == nil || bl.buckets == nil {
+		return []*Bucket{}
+	}
+	return bl.buckets
+}
+
+// Next returns the cursor to request the following page of the listing, or an empty string if this is the last one
+func (bl *BucketList) Next() string {
+	if bl == nil {
+		return ""
+	}
+	return bl.next
+}
+
+// paginate trims the list to the given limit, setting the cursor for the next page if there were more buckets
+func (bl *BucketList) paginate(limit int) {
+	if limit <= 0 || len(bl.buckets) <= limit {
+		return
+	}
+	bl.buckets = bl.buckets[:limit]
+	bl.next = base64.RawURLEncoding.EncodeToString([]byte(bl.buckets[limit-1].Name))
 }
 
 type bucketRepo struct {
@@ -46,7 +116,11 @@ func (r *bucketRepo) Insert(c *Bucket) error {
 		c.ID = bson.NewObjectId()
 	}
 	c.Touch()
-	return r.col.Insert(c)
+	err := r.col.Insert(c)
+	if mgo.IsDup(err) {
+		return ErrDuplicateKey
+	}
+	return err
 }
 
 func (r *bucketRepo) FindId(ID string) (*Bucket, error) {
@@ -58,9 +132,42 @@ func (r *bucketRepo) FindId(ID string) (*Bucket, error) {
 	return b, err
 }
 
+// Update saves the bucket, except for its statistics which are maintained by the object store.
+// The given bucket is reloaded with the stored statistics
 func (r *bucketRepo) Update(b *Bucket) error {
 	b.Touch()
-	return r.col.UpdateId(b.ID, b)
+	data, err := bson.Marshal(b)
+	if err != nil {
+		return err
+	}
+	doc := bson.M{}
+	if err = bson.Unmarshal(data, doc); err != nil {
+		return err
+	}
+	delete(doc, "_id")
+	delete(doc, "objects")
+	delete(doc, "size")
+
+	_, err = r.col.FindId(b.ID).Apply(mgo.Change{Update: bson.M{"$set": doc}, ReturnNew: true}, b)
+	if mgo.IsDup(err) {
+		return ErrDuplicateKey
+	}
+	return err
+}
+
+// addStats increments the object count and total size of a bucket
+func (r *bucketRepo) addStats(ID bson.ObjectId, objects int, size int64) error {
+	err := r.col.UpdateId(ID, bson.M{"$inc": bson.M{"objects": objects, "size": size}})
+	if err == mgo.ErrNotFound {
+		// Objects whose bucket has been deleted have no statistics to maintain
+		return nil
+	}
+	return err
+}
+
+// setStats replaces the object count and total size of a bucket
+func (r *bucketRepo) setStats(ID bson.ObjectId, objects int, size int64) error {
+	return r.col.UpdateId(ID, bson.M{"$set": bson.M{"objects": objects, "size": size}})
 }
 
 func (r *bucketRepo) DeleteId(ID string) error {
@@ -76,6 +183,39 @@ func (r *bucketRepo) FindName(name string) (*Bucket, error) {
 	return b, err
 }
 
+func (r *bucketRepo) Find(opts BucketListOptions) (*BucketList, error) {
+	after, err := opts.after()
+	if err != nil {
+		return nil, err
+	}
+	where := bson.M{}
+	if opts.Prefix != "" {
+		where["name"] = bson.RegEx{Pattern: "^" + regexp.QuoteMeta(opts.Prefix)}
+	}
+	if after != "" {
+		where["$and"] = []bson.M{{"name": bson.M{"$gt": after}}}
+	}
+
+	bl := &BucketList{buckets: []*Bucket{}}
+	iter := r.col.Find(where).Sort("name").Iter()
+	b := &Bucket{}
+	for iter.Next(b) {
+		if opts.match(b) {
+			bl.buckets = append(bl.buckets, b)
+			// Fetch an extra bucket to know whether there is a next page
+			if opts.Limit > 0 && len(bl.buckets) > opts.Limit {
+				break
+			}
+		}
+		b = &Bucket{}
+	}
+	if err = iter.Close(); err != nil {
+		return nil, err
+	}
+	bl.paginate(opts.Limit)
+	return bl, nil
+}
+
 func (r *bucketRepo) Exists(name string) bool {
 	_, err := r.FindName(name)
 	return err == nil
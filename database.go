@@ -18,6 +18,12 @@ var (
 	ErrNotFound = mgo.ErrNotFound
 	// ErrInvalidIDFormat error is returned when an invalid ObjectID is given
 	ErrInvalidIDFormat = errors.New("invalid format for resource ID")
+	// ErrInvalidCursor error is returned when an object listing is requested with a malformed cursor
+	ErrInvalidCursor = errors.New("invalid listing cursor")
+	// ErrInvalidSort error is returned when an object listing is requested with an unknown sort order
+	ErrInvalidSort = errors.New("invalid listing sort order")
+	// ErrDuplicateKey error is returned when storing a resource which violates a uniqueness constraint
+	ErrDuplicateKey = errors.New("duplicate key")
 )
 
 // DBOptions struct contains settings to create a new DBConn
@@ -0,0 +1,11 @@
+package goose
+
+import "errors"
+
+// errFileLocked is returned when trying to lock a file without waiting, while it is locked elsewhere
+var errFileLocked = errors.New("the file is locked")
+
+// The storage and upload directories shared by several processes are guarded with advisory locks on files in them,
+// taken with lockFile and released with unlockFile or by closing the file. The locks belong to the open file, so
+// two opens of the same file in one process also exclude each other. They are implemented with flock on Unix and
+// LockFileEx on Windows
@@ -0,0 +1,31 @@
+//go:build !windows
+// +build !windows
+
+package goose
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile locks an open file, exclusively or shared. Unless wait is set, it returns errFileLocked right away if
+// the lock is held elsewhere
+func lockFile(f *os.File, exclusive, wait bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	if !wait {
+		how |= syscall.LOCK_NB
+	}
+	err := syscall.Flock(int(f.Fd()), how)
+	if err == syscall.EWOULDBLOCK {
+		return errFileLocked
+	}
+	return err
+}
+
+// unlockFile releases the lock taken on a file with lockFile
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
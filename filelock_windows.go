@@ -0,0 +1,53 @@
+//go:build windows
+// +build windows
+
+package goose
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+
+	errorLockViolation syscall.Errno = 33
+)
+
+// lockFile locks an open file, exclusively or shared. Unless wait is set, it returns errFileLocked right away if
+// the lock is held elsewhere
+func lockFile(f *os.File, exclusive, wait bool) error {
+	var flags uintptr
+	if exclusive {
+		flags |= lockfileExclusiveLock
+	}
+	if !wait {
+		flags |= lockfileFailImmediately
+	}
+	// The whole file range is locked, as flock does
+	r, _, err := procLockFileEx.Call(f.Fd(), flags, 0, ^uintptr(0), ^uintptr(0), uintptr(unsafe.Pointer(&syscall.Overlapped{})))
+	if r != 0 {
+		return nil
+	}
+	if err == errorLockViolation {
+		return errFileLocked
+	}
+	return err
+}
+
+// unlockFile releases the lock taken on a file with lockFile
+func unlockFile(f *os.File) error {
+	r, _, err := procUnlockFileEx.Call(f.Fd(), 0, ^uintptr(0), ^uintptr(0), uintptr(unsafe.Pointer(&syscall.Overlapped{})))
+	if r != 0 {
+		return nil
+	}
+	return err
+}
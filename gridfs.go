@@ -0,0 +1,314 @@
+package goose
+
+import (
+	"io"
+	"regexp"
+	"sort"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func init() {
+	RegisterDBInitTask(func(db *DBConn) error { return NewObjectRepo(db).Init() })
+}
+
+type gridFSStorage struct {
+	db *DBConn
+}
+
+// NewGridFSStorage returns a storage backend that keeps the objects in MongoDB's GridFS
+// and the buckets in a regular collection of the same database
+func NewGridFSStorage(db *DBConn) Storage {
+	return &gridFSStorage{db: db}
+}
+
+func (s *gridFSStorage) Buckets() BucketStore {
+	return NewBucketRepo(s.db)
+}
+
+func (s *gridFSStorage) Objects() ObjectStore {
+	return NewObjectRepo(s.db)
+}
+
+func (s *gridFSStorage) APIKeys() APIKeyStore {
+	return NewAPIKeyRepo(s.db)
+}
+
+func (s *gridFSStorage) Users() UserStore {
+	return NewUserRepo(s.db)
+}
+
+func (s *gridFSStorage) Roles() RoleStore {
+	return NewRoleRepo(s.db)
+}
+
+func (s *gridFSStorage) Copy() Storage {
+	return &gridFSStorage{db: s.db.Copy()}
+}
+
+func (s *gridFSStorage) Close() {
+	s.db.Close()
+}
+
+// gridFSFile maps a document from the GridFS files collection
+type gridFSFile struct {
+	ID          bson.ObjectId   `bson:"_id"`
+	UploadDate  time.Time       `bson:"uploadDate"`
+	Length      int64           `bson:"length"`
+	MD5         string          `bson:"md5"`
+	Filename    string          `bson:"filename"`
+	ContentType string          `bson:"contentType"`
+	Metadata    *ObjectMetadata `bson:"metadata"`
+}
+
+func (f *gridFSFile) object() *Object {
+	return &Object{
+		ID:          f.ID,
+		UploadDate:  f.UploadDate,
+		Size:        f.Length,
+		MD5:         f.MD5,
+		Name:        f.Filename,
+		ContentType: f.ContentType,
+		Metadata:    f.Metadata,
+	}
+}
+
+func newObjectFromGridFile(f *mgo.GridFile, open bool) *Object {
+	obj := &Object{
+		ID:          f.Id().(bson.ObjectId),
+		UploadDate:  f.UploadDate(),
+		Size:        f.Size(),
+		MD5:         f.MD5(),
+		Name:        f.Name(),
+		ContentType: f.ContentType(),
+		Metadata:    &ObjectMetadata{},
+	}
+	f.GetMeta(obj.Metadata)
+	if open {
+		obj.file = f
+	}
+	return obj
+}
+
+type objectRepo struct {
+	gfs *mgo.GridFS
+	db  *DBConn
+}
+
+// NewObjectRepo returns an object store backed by the "fs" GridFS of the given database
+func NewObjectRepo(db *DBConn) *objectRepo {
+	return &objectRepo{gfs: db.GridFS("fs"), db: db}
+}
+
+func (or *objectRepo) Init() error {
+	index := mgo.Index{
+		Key:        []string{"filename", "metadata.bucketId"},
+		Unique:     false,
+		Background: false,
+		Sparse:     false,
+	}
+	if err := or.gfs.Files.EnsureIndex(index); err != nil {
+		return err
+	}
+	// Used by the paginated bucket listings
+	if err := or.gfs.Files.EnsureIndex(mgo.Index{Key: []string{"metadata.bucketId", "uploadDate", "_id"}}); err != nil {
+		return err
+	}
+	// Used by the prefix bucket listings
+	return or.gfs.Files.EnsureIndex(mgo.Index{Key: []string{"metadata.bucketId", "filename"}})
+}
+
+func (or *objectRepo) Create(r io.Reader, name, ctype string, metadata *ObjectMetadata) (*Object, error) {
+	gf, err := or.gfs.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	if ctype != "" {
+		gf.SetContentType(ctype)
+	}
+	if metadata != nil {
+		gf.SetMeta(metadata)
+	}
+	if _, err = io.Copy(gf, r); err != nil {
+		gf.Abort()
+		gf.Close()
+		return nil, err
+	}
+	// The MD5 checksum and upload date are computed when the file is closed
+	if err = gf.Close(); err != nil {
+		return nil, err
+	}
+	object := newObjectFromGridFile(gf, false)
+	if err = or.addStats(object, 1); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+func (r *objectRepo) UpdateMetadata(ID, name string, metadata ObjectMetadata) error {
+	if err := checkObjectId(ID); err != nil {
+		return err
+	}
+	return r.gfs.Files.UpdateId(bson.ObjectIdHex(ID), bson.M{"$set": bson.M{"metadata": metadata, "filename": name}})
+}
+
+func (r *objectRepo) FindId(ID string) (*Object, error) {
+	if err := checkObjectId(ID); err != nil {
+		return nil, err
+	}
+	f := &gridFSFile{}
+	if err := r.gfs.Files.FindId(bson.ObjectIdHex(ID)).One(f); err != nil {
+		return nil, err
+	}
+	return f.object(), nil
+}
+
+func (r *objectRepo) OpenId(ID string) (*Object, error) {
+	if err := checkObjectId(ID); err != nil {
+		return nil, err
+	}
+	gridFile, err := r.gfs.OpenId(bson.ObjectIdHex(ID))
+	if err != nil {
+		return nil, err
+	}
+	return newObjectFromGridFile(gridFile, true), nil
+}
+
+func (r *objectRepo) OpenFromBucket(filename string, bucketID bson.ObjectId) (*Object, error) {
+	iter := r.gfs.Find(bson.M{"filename": filename, "metadata.bucketId": bucketID}).Sort("-uploadDate").Iter()
+	defer iter.Close()
+
+	var f *mgo.GridFile
+	if r.gfs.OpenNext(iter, &f) {
+		return newObjectFromGridFile(f, true), nil
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return nil, ErrNotFound
+}
+
+func (r *objectRepo) DeleteId(ID string) error {
+	object, err := r.FindId(ID)
+	if err != nil {
+		return err
+	}
+	if err = r.gfs.RemoveId(object.ID); err != nil {
+		return err
+	}
+	return r.addStats(object, -1)
+}
+
+// addStats adds or subtracts (with a negative sign) the object to the statistics of its bucket
+func (r *objectRepo) addStats(object *Object, sign int) error {
+	if object.Metadata == nil || object.Metadata.BucketID == "" {
+		return nil
+	}
+	return NewBucketRepo(r.db).addStats(object.Metadata.BucketID, sign, int64(sign)*object.Size)
+}
+
+func (r *objectRepo) RecomputeBucketStats(bucketID bson.ObjectId) error {
+	var stats []struct {
+		Objects int   `bson:"objects"`
+		Size    int64 `bson:"size"`
+	}
+	pipe := r.gfs.Files.Pipe([]bson.M{
+		{"$match": bson.M{"metadata.bucketId": bucketID}},
+		{"$group": bson.M{"_id": nil, "objects": bson.M{"$sum": 1}, "size": bson.M{"$sum": "$length"}}},
+	})
+	if err := pipe.All(&stats); err != nil {
+		return err
+	}
+	if len(stats) == 0 {
+		return NewBucketRepo(r.db).setStats(bucketID, 0, 0)
+	}
+	return NewBucketRepo(r.db).setStats(bucketID, stats[0].Objects, stats[0].Size)
+}
+
+func (r *objectRepo) find(where bson.M, opts ListOptions) (*ObjectList, error) {
+	asc, err := opts.ascending()
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := opts.cursor()
+	if err != nil {
+		return nil, err
+	}
+	sortFields, op := []string{"-uploadDate", "-_id"}, "$lt"
+	if asc {
+		sortFields, op = []string{"uploadDate", "_id"}, "$gt"
+	}
+	if cursor != nil {
+		where["$or"] = []bson.M{
+			{"uploadDate": bson.M{op: cursor.UploadDate}},
+			{"uploadDate": cursor.UploadDate, "_id": bson.M{op: cursor.ID}},
+		}
+	}
+	query := r.gfs.Find(where).Sort(sortFields...)
+	if opts.Limit > 0 {
+		// Fetch an extra file to know whether there is a next page
+		query = query.Limit(opts.Limit + 1)
+	}
+	var files []gridFSFile
+	if err := query.All(&files); err != nil {
+		return nil, err
+	}
+	fl := &ObjectList{objects: make([]*Object, 0, len(files))}
+	for i := range files {
+		fl.objects = append(fl.objects, files[i].object())
+	}
+	fl.paginate(opts.Limit)
+	return fl, nil
+}
+
+func (r *objectRepo) FindByBucket(bucketID bson.ObjectId, opts ListOptions) (*ObjectList, error) {
+	prefix := "^" + regexp.QuoteMeta(opts.Prefix)
+	where := bson.M{"metadata.bucketId": bucketID}
+	if opts.Delimiter == "" {
+		if opts.Prefix != "" {
+			where["filename"] = bson.RegEx{Pattern: prefix}
+		}
+		return r.find(where, opts)
+	}
+
+	// Names without the delimiter after the prefix are listed by themselves
+	delim := regexp.QuoteMeta(opts.Delimiter)
+	where["filename"] = bson.RegEx{Pattern: prefix + `(?:(?!` + delim + `)[\s\S])*$`}
+	fl, err := r.find(where, opts)
+	if err != nil || opts.Cursor != "" {
+		return fl, err
+	}
+
+	// The rest are grouped in common prefixes
+	var names []string
+	where["filename"] = bson.RegEx{Pattern: prefix + `(?:(?!` + delim + `)[\s\S])*` + delim}
+	if err := r.gfs.Files.Find(where).Distinct("filename", &names); err != nil {
+		return nil, err
+	}
+	prefixes := make(map[string]bool)
+	for _, name := range names {
+		if p := opts.commonPrefix(name); p != "" && !prefixes[p] {
+			prefixes[p] = true
+			fl.prefixes = append(fl.prefixes, p)
+		}
+	}
+	sort.Strings(fl.prefixes)
+	return fl, nil
+}
+
+func (r *objectRepo) FindVersions(bucketID bson.ObjectId, name string) (*ObjectList, error) {
+	return r.find(bson.M{"filename": name, "metadata.bucketId": bucketID}, ListOptions{})
+}
+
+func (r *objectRepo) FindByIds(ids []string) (*ObjectList, error) {
+	var objIds []bson.ObjectId
+	for _, id := range ids {
+		if err := checkObjectId(id); err != nil {
+			return nil, err
+		}
+		objIds = append(objIds, bson.ObjectIdHex(id))
+	}
+	return r.find(bson.M{"_id": bson.M{"$in": objIds}}, ListOptions{})
+}
@@ -0,0 +1,262 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/syb-devs/goose"
+)
+
+// ErrUnauthorized represents an HTTP 401 error, returned when the request has no valid bearer token
+var ErrUnauthorized = NewError(401, "Unauthorized")
+
+// ErrUnsupportedJWTAlgorithm is returned when configuring the JWT authentication with an algorithm other than
+// HS256 and RS256
+var ErrUnsupportedJWTAlgorithm = errors.New("unsupported JWT algorithm, use HS256 or RS256")
+
+// JWTConfig holds the algorithm and key used to validate the JWT bearer tokens
+type JWTConfig struct {
+	algorithm string
+	key       interface{}
+	// AllowNoExpiry accepts the tokens without an exp claim, which are otherwise refused as they never expire
+	AllowNoExpiry bool
+	// AllowQueryToken accepts the tokens sent in the access_token query parameter, which are otherwise ignored.
+	// URLs end up in logs and browser histories, so signed URLs are a safer way to link to private objects
+	AllowQueryToken bool
+}
+
+// NewJWTConfig returns a JWT configuration for the given algorithm. The key is the shared secret for HS256,
+// or the PEM encoded public key for RS256
+func NewJWTConfig(algorithm string, key []byte) (*JWTConfig, error) {
+	switch algorithm {
+	case "HS256":
+		if len(key) == 0 {
+			return nil, errors.New("the JWT secret is empty")
+		}
+		return &JWTConfig{algorithm: algorithm, key: key}, nil
+	case "RS256":
+		pub, err := jwt.ParseRSAPublicKeyFromPEM(key)
+		if err != nil {
+			return nil, err
+		}
+		return &JWTConfig{algorithm: algorithm, key: pub}, nil
+	default:
+		return nil, ErrUnsupportedJWTAlgorithm
+	}
+}
+
+// JWTConfigFromEnv returns the JWT configuration set with the JWT_ALGORITHM (HS256 by default) and either the
+// JWT_KEY or JWT_KEY_FILE env vars, along with JWT_ALLOW_NO_EXPIRY and JWT_QUERY_TOKEN. It returns nil if no key
+// is set
+func JWTConfigFromEnv() (*JWTConfig, error) {
+	algorithm := os.Getenv("JWT_ALGORITHM")
+	if algorithm == "" {
+		algorithm = "HS256"
+	}
+	key := []byte(os.Getenv("JWT_KEY"))
+	if file := os.Getenv("JWT_KEY_FILE"); file != "" {
+		var err error
+		if key, err = ioutil.ReadFile(file); err != nil {
+			return nil, fmt.Errorf("error reading JWT key: %v", err)
+		}
+	}
+	if len(key) == 0 {
+		return nil, nil
+	}
+	c, err := NewJWTConfig(algorithm, key)
+	if err != nil {
+		return nil, err
+	}
+	if c.AllowNoExpiry, err = envBool("JWT_ALLOW_NO_EXPIRY"); err != nil {
+		return nil, err
+	}
+	if c.AllowQueryToken, err = envBool("JWT_QUERY_TOKEN"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// envBool returns the boolean value of an env var, false if not set
+func envBool(name string) (bool, error) {
+	env := os.Getenv(name)
+	if env == "" {
+		return false, nil
+	}
+	value, err := strconv.ParseBool(env)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s, use true or false", name)
+	}
+	return value, nil
+}
+
+var jwtConfig *JWTConfig
+
+// SetJWTConfig enables the authentication of the requests with JWT bearer tokens. With a nil configuration,
+// bearer tokens are refused, as they can not be validated
+func SetJWTConfig(c *JWTConfig) {
+	jwtConfig = c
+}
+
+// authDisabled makes every request be made by a user with access to everything
+var authDisabled bool
+
+// SetAuthDisabled turns the authentication of the requests off, so every request is made by a user with access
+// to everything. Meant for development only
+func SetAuthDisabled(disabled bool) {
+	authDisabled = disabled
+}
+
+// AuthDisabledFromEnv tells whether authentication is turned off with AUTH_DISABLED=true
+func AuthDisabledFromEnv() (bool, error) {
+	return envBool("AUTH_DISABLED")
+}
+
+// userClaims are the claims of the tokens, with the roles, groups and allowed buckets of the user
+type userClaims struct {
+	Roles   []string `json:"roles,omitempty"`
+	Groups  []string `json:"groups,omitempty"`
+	Buckets []string `json:"buckets,omitempty"`
+	jwt.StandardClaims
+}
+
+// User validates a token and returns the user it was issued to. Tokens without an expiry time are refused unless
+// AllowNoExpiry is set
+func (c *JWTConfig) User(token string) (*goose.User, error) {
+	claims := &userClaims{}
+	parser := &jwt.Parser{ValidMethods: []string{c.algorithm}}
+	_, err := parser.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return c.key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("the token has no subject")
+	}
+	if claims.ExpiresAt == 0 && !c.AllowNoExpiry {
+		return nil, errors.New("the token has no expiry time")
+	}
+	return &goose.User{Subject: claims.Subject, Roles: claims.Roles, Groups: claims.Groups, Buckets: claims.Buckets}, nil
+}
+
+// apiKeyTouchInterval is how often the last-used time of the API keys is updated
+const apiKeyTouchInterval = time.Minute
+
+// authenticate returns the user making the request, given by the API key in the X-API-Key header, or the bearer
+// token in the Authorization header or, if allowed, the access_token query parameter. It is nil when the request has
+// neither.
+// The stored record of the user, if any, is merged into it
+func authenticate(r *http.Request, storage goose.Storage) (*goose.User, error) {
+	user, err := requestUser(r, storage)
+	if user == nil || err != nil {
+		return user, err
+	}
+	return LoadUserRecord(storage, user)
+}
+
+// LoadUserRecord merges the stored record of an authenticated user into it, if there is one. Disabled users are
+// refused with ErrUnauthorized
+func LoadUserRecord(storage goose.Storage, user *goose.User) (*goose.User, error) {
+	if user.Subject == "" {
+		return user, nil
+	}
+	record, err := storage.Users().FindSubject(user.Subject)
+	if err == goose.ErrNotFound {
+		return user, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if record.Disabled {
+		return nil, ErrUnauthorized
+	}
+	user.Merge(record)
+	return user, nil
+}
+
+// requestUser returns the user given by the credentials of the request
+func requestUser(r *http.Request, storage goose.Storage) (*goose.User, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return authenticateAPIKey(storage, key)
+	}
+	if authDisabled {
+		return &goose.User{Roles: []string{goose.RoleAdmin}}, nil
+	}
+	var token string
+	if jwtConfig != nil && jwtConfig.AllowQueryToken {
+		token = r.URL.Query().Get("access_token")
+	}
+	if header := r.Header.Get("Authorization"); header != "" {
+		if !strings.HasPrefix(header, "Bearer ") {
+			return nil, ErrUnauthorized
+		}
+		token = strings.TrimSpace(header[len("Bearer "):])
+	}
+	if token == "" {
+		return nil, nil
+	}
+	if jwtConfig == nil {
+		return nil, ErrUnauthorized
+	}
+	user, err := jwtConfig.User(token)
+	if err != nil {
+		goose.Log.Debug(fmt.Sprintf("invalid bearer token: %v", err))
+		return nil, ErrUnauthorized
+	}
+	return user, nil
+}
+
+// authenticateAPIKey returns the user of an API key, recording when the key was used
+func authenticateAPIKey(storage goose.Storage, key string) (*goose.User, error) {
+	ID, secret, err := goose.ParseAPIKey(key)
+	if err != nil {
+		return nil, ErrUnauthorized
+	}
+	repo := storage.APIKeys()
+	k, err := repo.FindId(ID)
+	if err == goose.ErrNotFound {
+		return nil, ErrUnauthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !k.Check(secret) {
+		return nil, ErrUnauthorized
+	}
+	now := time.Now()
+	if k.LastUsed == nil || now.Sub(*k.LastUsed) > apiKeyTouchInterval {
+		if err = repo.Touch(ID, now); err != nil {
+			goose.Log.Error(fmt.Sprintf("error updating the last use of API key %s: %v", ID, err))
+		}
+	}
+	return k.User(), nil
+}
+
+// Authenticated wraps a handler so it is only run for authenticated users, returning an HTTP 401 error otherwise
+func Authenticated(f HandlerFunc) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, ctx *Context) error {
+		if ctx.User == nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			return ErrUnauthorized
+		}
+		return f(w, r, ctx)
+	}
+}
+
+// Scoped wraps a handler so it is only run for users allowed the operations of the scope, returning an HTTP 403
+// error otherwise. An empty scope allows every user. Anonymous requests are let through, the handler must check them
+func Scoped(scope string, f HandlerFunc) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, ctx *Context) error {
+		if scope != "" && ctx.User != nil && !ctx.User.HasScope(scope) {
+			return NewError(403, "the API key is missing the "+scope+" scope")
+		}
+		return f(w, r, ctx)
+	}
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"time"
+
+	"github.com/syb-devs/goose"
+)
+
+type APIKeysService struct {
+	s *Service
+}
+
+func NewAPIKeysService(s *Service) *APIKeysService {
+	return &APIKeysService{s: s}
+}
+
+// NewAPIKey holds the settings of an API key to create
+type NewAPIKey struct {
+	Name string `json:",omitempty"`
+	// Owner is the subject of the user the key acts on behalf of. Defaults to the user creating it
+	Owner   string     `json:",omitempty"`
+	Roles   []string   `json:",omitempty"`
+	Scopes  []string   `json:",omitempty"`
+	Buckets []string   `json:",omitempty"`
+	Expires *time.Time `json:",omitempty"`
+}
+
+// CreatedAPIKey is a new API key, along with its secret key. The key can not be retrieved again
+type CreatedAPIKey struct {
+	goose.APIKey
+	Key string `json:"key"`
+}
+
+// Create creates an API key. It requires the admin role
+func (sv *APIKeysService) Create(key *NewAPIKey) (*CreatedAPIKey, error) {
+	url, err := sv.s.url("/apikeys", nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := sv.s.sendJSON("POST", url, key)
+	if err != nil {
+		return nil, err
+	}
+	created := &CreatedAPIKey{}
+	if err = decodeJSON(res.Body, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// List returns the API keys of an owner, or every key if owner is empty, newest first
+func (sv *APIKeysService) List(owner string) ([]goose.APIKey, error) {
+	var ps *URLParams
+	if owner != "" {
+		ps = &URLParams{Query: dict{"owner": owner}}
+	}
+	url, err := sv.s.url("/apikeys", ps)
+	if err != nil {
+		return nil, err
+	}
+	keys := []goose.APIKey{}
+	if err = sv.s.getInto(url, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (sv *APIKeysService) Retrieve(keyID string) (*goose.APIKey, error) {
+	if !goose.ValidObjectID(keyID) {
+		return nil, goose.ErrInvalidIDFormat
+	}
+	url, err := sv.s.url("/apikeys/"+keyID, nil)
+	if err != nil {
+		return nil, err
+	}
+	key := &goose.APIKey{}
+	if err = sv.s.getInto(url, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Revoke deletes an API key, which can no longer be used
+func (sv *APIKeysService) Revoke(keyID string) error {
+	if !goose.ValidObjectID(keyID) {
+		return goose.ErrInvalidIDFormat
+	}
+	url, err := sv.s.url("/apikeys/"+keyID, nil)
+	if err != nil {
+		return err
+	}
+	_, err = sv.s.delete(url)
+	return err
+}
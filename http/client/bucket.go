@@ -1,6 +1,8 @@
 package client
 
 import (
+	"strconv"
+
 	"github.com/syb-devs/goose"
 )
 
@@ -52,3 +54,119 @@ func (sv *BucketsService) RetrieveByName(name string) (*goose.Bucket, error) {
 	}
 	return bucket, nil
 }
+
+// BucketChanges holds the bucket fields to update. Nil fields are left unchanged
+type BucketChanges struct {
+	Name         *string            `json:",omitempty"`
+	Versioning   *bool              `json:",omitempty"`
+	Quota        *goose.BucketQuota `json:",omitempty"`
+	Visibility   *goose.Visibility  `json:",omitempty"`
+	CacheControl *string            `json:",omitempty"`
+}
+
+// Update applies the given changes to a bucket, returning the updated bucket
+func (sv *BucketsService) Update(bucketID string, changes *BucketChanges) (*goose.Bucket, error) {
+	if !goose.ValidObjectID(bucketID) {
+		return nil, ErrInvalidBucketID
+	}
+	url, err := sv.s.url("/buckets/"+bucketID, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := sv.s.sendJSON("PUT", url, changes)
+	if err != nil {
+		return nil, err
+	}
+	bucket := &goose.Bucket{}
+	if err = decodeJSON(res.Body, bucket); err != nil {
+		return nil, err
+	}
+	return bucket, nil
+}
+
+// Delete deletes a bucket. Buckets with objects are only deleted with force, and then their objects
+// are deleted in the background by the server
+func (sv *BucketsService) Delete(bucketID string, force bool) error {
+	if !goose.ValidObjectID(bucketID) {
+		return ErrInvalidBucketID
+	}
+	var ps *URLParams
+	if force {
+		ps = &URLParams{Query: dict{"force": "true"}}
+	}
+	url, err := sv.s.url("/buckets/"+bucketID, ps)
+	if err != nil {
+		return err
+	}
+	_, err = sv.s.delete(url)
+	return err
+}
+
+// BucketList is a page of the buckets the user can read
+type BucketList struct {
+	Buckets []goose.Bucket `json:"buckets"`
+	// Next is the cursor to request the following page, empty if this is the last one
+	Next string `json:"next"`
+}
+
+// List returns a page of the buckets the user can read, sorted by name. To get the following page, call it again
+// with the Next cursor of the returned list in ops.Cursor. The Filter option is not supported
+func (sv *BucketsService) List(ops *goose.BucketListOptions) (*BucketList, error) {
+	query := dict{}
+	if ops != nil {
+		if ops.Limit > 0 {
+			query["limit"] = strconv.Itoa(ops.Limit)
+		}
+		if ops.Prefix != "" {
+			query["prefix"] = ops.Prefix
+		}
+		if ops.Cursor != "" {
+			query["cursor"] = ops.Cursor
+		}
+	}
+	url, err := sv.s.url("/buckets", &URLParams{Query: query})
+	if err != nil {
+		return nil, err
+	}
+	bList := &BucketList{}
+	if err = sv.s.getInto(url, bList); err != nil {
+		return nil, err
+	}
+	return bList, nil
+}
+
+// ACL returns the access control list of a bucket
+func (sv *BucketsService) ACL(bucketID string) (*goose.ACL, error) {
+	if !goose.ValidObjectID(bucketID) {
+		return nil, ErrInvalidBucketID
+	}
+	url, err := sv.s.url("/buckets/"+bucketID+"/acl", nil)
+	if err != nil {
+		return nil, err
+	}
+	acl := &goose.ACL{}
+	if err = sv.s.getInto(url, acl); err != nil {
+		return nil, err
+	}
+	return acl, nil
+}
+
+// SetACL replaces the access control list of a bucket, returning the new one. The owner is kept if acl has none
+func (sv *BucketsService) SetACL(bucketID string, acl *goose.ACL) (*goose.ACL, error) {
+	if !goose.ValidObjectID(bucketID) {
+		return nil, ErrInvalidBucketID
+	}
+	url, err := sv.s.url("/buckets/"+bucketID+"/acl", nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := sv.s.sendJSON("PUT", url, acl)
+	if err != nil {
+		return nil, err
+	}
+	newACL := &goose.ACL{}
+	if err = decodeJSON(res.Body, newACL); err != nil {
+		return nil, err
+	}
+	return newACL, nil
+}
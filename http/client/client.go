@@ -27,18 +27,46 @@ type URLParams struct {
 
 type Service struct {
 	BaseURL string
+	// FilesURL is the base URL of the file server, used to open objects by name
+	FilesURL string
+	// Token is the JWT sent as bearer token to authenticate the requests
+	Token string
+	// APIKey is the API key sent to authenticate the requests, instead of the token
+	APIKey  string
 	client  *http.Client
 	Objects *ObjectsService
 	Buckets *BucketsService
+	APIKeys *APIKeysService
+	Users   *UsersService
+	Roles   *RolesService
 }
 
-func New(client *http.Client, baseURL string) (*Service, error) {
+// Option configures the service created by New
+type Option func(s *Service)
+
+// WithToken authenticates the requests with a JWT bearer token
+func WithToken(token string) Option {
+	return func(s *Service) { s.Token = token }
+}
+
+// WithAPIKey authenticates the requests with an API key
+func WithAPIKey(key string) Option {
+	return func(s *Service) { s.APIKey = key }
+}
+
+func New(client *http.Client, baseURL string, opts ...Option) (*Service, error) {
 	if client == nil {
 		return nil, errors.New("client is nil")
 	}
 	s := &Service{client: client, BaseURL: baseURL}
+	for _, opt := range opts {
+		opt(s)
+	}
 	s.Buckets = NewBucketsService(s)
 	s.Objects = NewObjectsService(s)
+	s.APIKeys = NewAPIKeysService(s)
+	s.Users = NewUsersService(s)
+	s.Roles = NewRolesService(s)
 
 	return s, nil
 }
@@ -50,6 +78,11 @@ func (s *Service) newRequest(method, url string, body io.Reader) (*http.Request,
 	}
 	req.Header.Set("User-Agent", userAgent)
 	req.Header.Set("Content-Type", "application/json")
+	if s.APIKey != "" {
+		req.Header.Set("X-API-Key", s.APIKey)
+	} else if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
 	return req, nil
 }
 
@@ -0,0 +1,219 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/syb-devs/goose"
+	ghttp "github.com/syb-devs/goose/http"
+)
+
+const defaultDownloadRetries = 3
+
+var (
+	// ErrNoFilesURL is returned when opening an object by name without setting the file server URL of the service
+	ErrNoFilesURL = errors.New("the file server URL is not set")
+	// ErrObjectChanged is returned when resuming a download of an object that has been replaced in the meantime
+	ErrObjectChanged = errors.New("the object changed while downloading it")
+)
+
+// DownloadOptions controls the part of an object that is downloaded, and how dropped connections are handled
+type DownloadOptions struct {
+	// Offset is the position of the first byte to download
+	Offset int64
+	// Length is the number of bytes to download, 0 to download up to the end of the object
+	Length int64
+	// Retries is the number of times a dropped download is resumed. Defaults to 3, use a negative value to disable it
+	Retries int
+}
+
+// Download streams the data of an object from the API. IMPORTANT: close the returned reader when no longer needed
+func (sv *ObjectsService) Download(bucketID, objectID string, ops *DownloadOptions) (io.ReadCloser, error) {
+	if !goose.ValidObjectID(bucketID) {
+		return nil, ErrInvalidBucketID
+	}
+	if !goose.ValidObjectID(objectID) {
+		return nil, ErrInvalidObjectID
+	}
+	url, err := sv.s.url("/buckets/"+bucketID+"/objects/"+objectID+"/data", nil)
+	if err != nil {
+		return nil, err
+	}
+	return sv.s.download(url, ops)
+}
+
+// Open streams the newest version of the object with the given name from the file server, as it is served to
+// the public. IMPORTANT: close the returned reader when no longer needed
+func (sv *ObjectsService) Open(bucketName, name string, ops *DownloadOptions) (io.ReadCloser, error) {
+	if sv.s.FilesURL == "" {
+		return nil, ErrNoFilesURL
+	}
+	if bucketName == "" {
+		return nil, ErrInvalidBucketName
+	}
+	if name == "" {
+		return nil, ErrInvalidObjectName
+	}
+	path := &url.URL{Path: "/" + bucketName + ghttp.PrefixSlash(name)}
+	return sv.s.download(sv.s.FilesURL+path.EscapedPath(), ops)
+}
+
+func (s *Service) download(url string, ops *DownloadOptions) (io.ReadCloser, error) {
+	d := &download{s: s, url: url, end: -1, retries: defaultDownloadRetries}
+	if ops != nil {
+		d.offset = ops.Offset
+		if ops.Length > 0 {
+			d.end = ops.Offset + ops.Length
+		}
+		if ops.Retries != 0 {
+			d.retries = ops.Retries
+		}
+	}
+	if err := d.open(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// download reads the data of an object, resuming the download with a Range request if the connection is dropped
+type download struct {
+	s       *Service
+	url     string
+	body    io.ReadCloser
+	offset  int64 // position of the next byte to read
+	end     int64 // position after the last byte to read, or -1 to read up to the end
+	retries int
+	// validator is the ETag or Last-Modified header of the first response, to check that the object
+	// does not change when resuming the download
+	validator string
+}
+
+func (d *download) open() error {
+	req, err := d.s.newRequest("GET", d.url, nil)
+	if err != nil {
+		return err
+	}
+	if d.offset > 0 || d.end >= 0 {
+		if d.end >= 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", d.offset, d.end-1))
+		} else {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", d.offset))
+		}
+		if d.validator != "" {
+			req.Header.Set("If-Range", d.validator)
+		}
+	}
+	res, err := d.s.do(req)
+	if err != nil {
+		if res != nil {
+			res.Body.Close()
+		}
+		return err
+	}
+
+	validator := res.Header.Get("ETag")
+	if validator == "" {
+		validator = res.Header.Get("Last-Modified")
+	}
+	if d.validator != "" && validator != d.validator {
+		res.Body.Close()
+		return ErrObjectChanged
+	}
+	d.validator = validator
+
+	d.body = res.Body
+	if res.StatusCode == http.StatusPartialContent {
+		return nil
+	}
+	// The server sent the whole object, so skip the bytes before the offset and stop at the end
+	if d.offset > 0 {
+		if _, err = io.CopyN(ioutil.Discard, res.Body, d.offset); err != nil {
+			res.Body.Close()
+			return err
+		}
+	}
+	if d.end >= 0 {
+		d.body = struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(res.Body, d.end-d.offset), res.Body}
+	}
+	return nil
+}
+
+func (d *download) Read(p []byte) (int, error) {
+	for {
+		n, err := d.body.Read(p)
+		d.offset += int64(n)
+		if err == nil || err == io.EOF || d.retries <= 0 {
+			return n, err
+		}
+		// The connection was dropped, resume the download where it stopped
+		d.retries--
+		d.body.Close()
+		if rerr := d.open(); rerr != nil {
+			return n, rerr
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (d *download) Close() error {
+	return d.body.Close()
+}
+
+// SignedURLOptions controls the signed URLs issued to download objects from the file server
+type SignedURLOptions struct {
+	// Expires is how long the URL is valid for. Defaults to one hour, and can be up to 7 days
+	Expires time.Duration
+	// IP, if set, is the only client address allowed to use the URL
+	IP string
+	// Disposition, if set, is sent as the Content-Disposition header, like `attachment; filename="report.pdf"`
+	Disposition string
+}
+
+// SignedURL is a temporary link to an object version in the file server, valid regardless of the bucket visibility
+type SignedURL struct {
+	URL     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
+// SignURL issues a signed URL to download an object from the file server until it expires
+func (sv *ObjectsService) SignURL(bucketID, objectID string, ops *SignedURLOptions) (*SignedURL, error) {
+	if !goose.ValidObjectID(bucketID) {
+		return nil, ErrInvalidBucketID
+	}
+	if !goose.ValidObjectID(objectID) {
+		return nil, ErrInvalidObjectID
+	}
+	url, err := sv.s.url("/buckets/"+bucketID+"/objects/"+objectID+"/url", nil)
+	if err != nil {
+		return nil, err
+	}
+	req := struct {
+		Expires     int    `json:",omitempty"`
+		IP          string `json:",omitempty"`
+		Disposition string `json:",omitempty"`
+	}{}
+	if ops != nil {
+		req.Expires = int(ops.Expires / time.Second)
+		req.IP = ops.IP
+		req.Disposition = ops.Disposition
+	}
+	res, err := sv.s.sendJSON("POST", url, req)
+	if err != nil {
+		return nil, err
+	}
+	signed := &SignedURL{}
+	if err = decodeJSON(res.Body, signed); err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
@@ -46,9 +46,9 @@ func main() {
 
 	// List all objects in the bucket
 	print("\nlisting bucket files...")
-	objects, err := service.Objects.List(bucket.ID.Hex())
+	objects, err := service.Objects.List(bucket.ID.Hex(), nil)
 	handle(err)
-	print("\nbucket objects: %+v", objects)
+	print("\nbucket objects: %+v", objects.Objects)
 
 	print("\ndeleting uploaded file...")
 	// Delete the uploaded file
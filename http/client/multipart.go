@@ -0,0 +1,215 @@
+package client
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/syb-devs/goose"
+)
+
+const (
+	// DefaultPartSize is the size of the parts uploaded by UploadParallel, unless set in the options
+	DefaultPartSize = 8 << 20
+	// DefaultConcurrency is the number of parts uploaded at the same time by UploadParallel, unless set in the options
+	DefaultConcurrency = 4
+)
+
+// ErrPartChecksum is returned when the checksum of an uploaded part, computed by the server, does not match the
+// data sent
+var ErrPartChecksum = errors.New("the checksum of the uploaded part does not match")
+
+// InitiateUpload starts a multipart upload of an object, whose parts can then be uploaded concurrently
+func (sv *ObjectsService) InitiateUpload(bucketID, name, contentType string, metadata *MetadataChanges) (*goose.MultipartUpload, error) {
+	if !goose.ValidObjectID(bucketID) {
+		return nil, ErrInvalidBucketID
+	}
+	if name == "" {
+		return nil, ErrInvalidObjectName
+	}
+	url, err := sv.s.url("/buckets/"+bucketID+"/uploads", nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := sv.s.sendJSON("POST", url, struct {
+		Name        string
+		ContentType string
+		Metadata    *MetadataChanges `json:",omitempty"`
+	}{name, contentType, metadata})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	upload := &goose.MultipartUpload{}
+	if err = decodeJSON(res.Body, upload); err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+// UploadPart uploads a part of a multipart upload, with size bytes read from data. Parts are numbered from 1 to
+// goose.MaxPartNumber, and uploading a part again replaces it
+func (sv *ObjectsService) UploadPart(bucketID, uploadID string, number int, data io.Reader, size int64) (*goose.Part, error) {
+	if !goose.ValidObjectID(bucketID) {
+		return nil, ErrInvalidBucketID
+	}
+	url, err := sv.s.url("/buckets/"+bucketID+"/uploads/"+uploadID+"/parts/"+strconv.Itoa(number), nil)
+	if err != nil {
+		return nil, err
+	}
+	req, err := sv.s.newRequest("PUT", url, data)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.ContentLength = size
+	res, err := sv.s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	part := &goose.Part{}
+	if err = decodeJSON(res.Body, part); err != nil {
+		return nil, err
+	}
+	return part, nil
+}
+
+// CompleteUpload joins the given parts of a multipart upload, in ascending order of their numbers, into the
+// object. The checksums of the parts are checked if set. Without parts, all the uploaded ones are joined
+func (sv *ObjectsService) CompleteUpload(bucketID, uploadID string, parts []goose.Part) (*goose.Object, error) {
+	if !goose.ValidObjectID(bucketID) {
+		return nil, ErrInvalidBucketID
+	}
+	url, err := sv.s.url("/buckets/"+bucketID+"/uploads/"+uploadID+"/complete", nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := sv.s.sendJSON("POST", url, struct{ Parts []goose.Part }{parts})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	object := &goose.Object{}
+	if err = decodeJSON(res.Body, object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+// AbortUpload discards a multipart upload along with its parts
+func (sv *ObjectsService) AbortUpload(bucketID, uploadID string) error {
+	if !goose.ValidObjectID(bucketID) {
+		return ErrInvalidBucketID
+	}
+	url, err := sv.s.url("/buckets/"+bucketID+"/uploads/"+uploadID, nil)
+	if err != nil {
+		return err
+	}
+	res, err := sv.s.delete(url)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// ParallelOptions configures the uploads of UploadParallel
+type ParallelOptions struct {
+	// PartSize is the size of the parts, DefaultPartSize if not set. It is increased if the data would need more
+	// than goose.MaxPartNumber parts
+	PartSize int64
+	// Concurrency is the maximum number of parts uploaded at the same time, DefaultConcurrency if not set
+	Concurrency int
+	// Metadata is the metadata of the object
+	Metadata *MetadataChanges
+}
+
+// UploadParallel uploads an object with size bytes read from data, splitting it in parts uploaded concurrently
+// with a multipart upload. If any part fails, the upload is aborted and the first error returned
+func (sv *ObjectsService) UploadParallel(bucketID, name, contentType string, data io.ReaderAt, size int64, opts *ParallelOptions) (*goose.Object, error) {
+	if opts == nil {
+		opts = &ParallelOptions{}
+	}
+	partSize, concurrency := opts.PartSize, opts.Concurrency
+	if partSize <= 0 {
+		partSize = DefaultPartSize
+	}
+	if min := (size + goose.MaxPartNumber - 1) / goose.MaxPartNumber; partSize < min {
+		partSize = min
+	}
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	count := int((size + partSize - 1) / partSize)
+	if count == 0 {
+		// Empty objects are made of a single empty part
+		count = 1
+	}
+
+	upload, err := sv.InitiateUpload(bucketID, name, contentType, opts.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	parts := make([]goose.Part, count)
+	numbers := make(chan int)
+	done := make(chan struct{})
+	var once sync.Once
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency && i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for number := range numbers {
+				part, perr := sv.uploadSection(bucketID, upload.ID, number, data, partSize, size)
+				if perr != nil {
+					once.Do(func() {
+						err = perr
+						close(done)
+					})
+					return
+				}
+				parts[number-1] = *part
+			}
+		}()
+	}
+send:
+	for number := 1; number <= count; number++ {
+		select {
+		case numbers <- number:
+		case <-done:
+			break send
+		}
+	}
+	close(numbers)
+	wg.Wait()
+
+	if err != nil {
+		if aerr := sv.AbortUpload(bucketID, upload.ID); aerr != nil {
+			return nil, newCtxErr("aborting the upload after "+err.Error(), aerr)
+		}
+		return nil, err
+	}
+	return sv.CompleteUpload(bucketID, upload.ID, parts)
+}
+
+// uploadSection uploads the part with the given number of the data split in parts of partSize bytes, checking
+// the checksum computed by the server
+func (sv *ObjectsService) uploadSection(bucketID, uploadID string, number int, data io.ReaderAt, partSize, size int64) (*goose.Part, error) {
+	offset := int64(number-1) * partSize
+	n := partSize
+	if offset+n > size {
+		n = size - offset
+	}
+	h := md5.New()
+	part, err := sv.UploadPart(bucketID, uploadID, number, io.TeeReader(io.NewSectionReader(data, offset, n), h), n)
+	if err != nil {
+		return nil, newCtxErr("uploading part "+strconv.Itoa(number), err)
+	}
+	if part.MD5 != hex.EncodeToString(h.Sum(nil)) || part.Size != n {
+		return nil, ErrPartChecksum
+	}
+	return part, nil
+}
@@ -5,11 +5,13 @@ import (
 	// "fmt"
 	"github.com/syb-devs/goose"
 	"io"
+	"strconv"
 	"strings"
 )
 
 var (
 	ErrInvalidBucketID   = errors.New("invalid bucket id")
+	ErrInvalidBucketName = errors.New("invalid bucket name")
 	ErrInvalidObjectID   = errors.New("invalid object id")
 	ErrInvalidObjectName = errors.New("invalid object name")
 )
@@ -78,6 +80,39 @@ func (sv *ObjectsService) Delete(bucketID, objectID string) error {
 	return err
 }
 
+// MetadataChanges holds the object metadata fields to update. Nil fields are left unchanged,
+// and the Custom entries are merged into the existing ones
+type MetadataChanges struct {
+	Title        *string                `json:",omitempty"`
+	Description  *string                `json:",omitempty"`
+	CacheControl *string                `json:",omitempty"`
+	Tags         []string               `json:",omitempty"`
+	Custom       map[string]interface{} `json:",omitempty"`
+}
+
+// UpdateMetadata applies the given changes to the metadata of an object, returning the updated object
+func (sv *ObjectsService) UpdateMetadata(bucketID, objectID string, changes *MetadataChanges) (*goose.Object, error) {
+	if !goose.ValidObjectID(bucketID) {
+		return nil, ErrInvalidBucketID
+	}
+	if !goose.ValidObjectID(objectID) {
+		return nil, ErrInvalidObjectID
+	}
+	url, err := sv.s.url("/buckets/"+bucketID+"/objects/"+objectID+"/metadata", nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := sv.s.sendJSON("PUT", url, changes)
+	if err != nil {
+		return nil, err
+	}
+	object := &goose.Object{}
+	if err = decodeJSON(res.Body, object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
 func (sv *ObjectsService) Retrieve(bucketID, objectID string) (*goose.Object, error) {
 	url, err := sv.s.url("/buckets/"+bucketID+"/objects/"+objectID, nil)
 	if err != nil {
@@ -103,13 +138,46 @@ func (sv *ObjectsService) RetrieveMany(bucketID string, objectIDs []string) ([]g
 	return oList, nil
 }
 
-func (sv *ObjectsService) List(bucketID string) ([]goose.Object, error) {
-	url, err := sv.s.url("/buckets/"+bucketID+"/objects", nil)
+// ObjectList is a page of the objects stored in a bucket
+type ObjectList struct {
+	Objects []goose.Object `json:"objects"`
+	// Prefixes are the common prefixes of a delimited listing, only returned along with the first page
+	Prefixes []string `json:"prefixes"`
+	// Next is the cursor to request the following page, empty if this is the last one
+	Next string `json:"next"`
+}
+
+// List returns a page of the objects stored in a bucket. To get the following page, call it again
+// with the Next cursor of the returned list in ops.Cursor
+func (sv *ObjectsService) List(bucketID string, ops *goose.ListOptions) (*ObjectList, error) {
+	query := dict{}
+	if ops != nil {
+		if ops.Limit > 0 {
+			query["limit"] = strconv.Itoa(ops.Limit)
+		}
+		if ops.Sort != "" {
+			query["sort"] = ops.Sort
+		}
+		if ops.Cursor != "" {
+			query["cursor"] = ops.Cursor
+		}
+		if ops.Prefix != "" {
+			query["prefix"] = ops.Prefix
+		}
+		if ops.Delimiter != "" {
+			query["delimiter"] = ops.Delimiter
+		}
+	}
+	ps := &URLParams{
+		Path:  dict{"bucket": bucketID},
+		Query: query,
+	}
+	url, err := sv.s.url("/buckets/{bucket}/objects", ps)
 	if err != nil {
 		return nil, err
 	}
-	oList := []goose.Object{}
-	if err = sv.s.getInto(url, &oList); err != nil {
+	oList := &ObjectList{}
+	if err = sv.s.getInto(url, oList); err != nil {
 		return nil, err
 	}
 	return oList, nil
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"time"
+
+	"github.com/syb-devs/goose"
+)
+
+// UploadPolicyOptions are the conditions of the uploads allowed by a policy
+type UploadPolicyOptions struct {
+	// Expires is how long the policy is valid for. Defaults to one hour, and can be up to 7 days
+	Expires time.Duration
+	// Prefix, if set, is the prefix the object names must start with
+	Prefix string
+	// MaxSize, if set, is the maximum size of the objects, in bytes
+	MaxSize int64
+	// ContentTypes, if set, are the allowed content types. A type like image/* allows all its subtypes
+	ContentTypes []string
+}
+
+// UploadPolicy is a signed policy that allows uploading objects to a bucket without credentials. Browsers can POST
+// the objects to the API URL (relative to the service BaseURL) adding the name query parameter, or send the policy,
+// signature and name as form fields along with the object in the "object" form field
+type UploadPolicy struct {
+	Policy    string    `json:"policy"`
+	Signature string    `json:"signature"`
+	URL       string    `json:"url"`
+	Expires   time.Time `json:"expires"`
+}
+
+// UploadPolicy issues a signed policy to upload objects to a bucket
+func (sv *ObjectsService) UploadPolicy(bucketID string, ops *UploadPolicyOptions) (*UploadPolicy, error) {
+	if !goose.ValidObjectID(bucketID) {
+		return nil, ErrInvalidBucketID
+	}
+	url, err := sv.s.url("/buckets/"+bucketID+"/policies", nil)
+	if err != nil {
+		return nil, err
+	}
+	req := struct {
+		Expires      int      `json:",omitempty"`
+		Prefix       string   `json:",omitempty"`
+		MaxSize      int64    `json:",omitempty"`
+		ContentTypes []string `json:",omitempty"`
+	}{}
+	if ops != nil {
+		req.Expires = int(ops.Expires / time.Second)
+		req.Prefix = ops.Prefix
+		req.MaxSize = ops.MaxSize
+		req.ContentTypes = ops.ContentTypes
+	}
+	res, err := sv.s.sendJSON("POST", url, req)
+	if err != nil {
+		return nil, err
+	}
+	policy := &UploadPolicy{}
+	if err = decodeJSON(res.Body, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
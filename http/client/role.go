@@ -0,0 +1,91 @@
+package client
+
+import (
+	"github.com/syb-devs/goose"
+)
+
+type RolesService struct {
+	s *Service
+}
+
+func NewRolesService(s *Service) *RolesService {
+	return &RolesService{s: s}
+}
+
+// Create creates a role. It requires the admin role
+func (sv *RolesService) Create(name, description string) (*goose.Role, error) {
+	url, err := sv.s.url("/roles", nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := sv.s.sendJSON("POST", url, dict{"Name": name, "Description": description})
+	if err != nil {
+		return nil, err
+	}
+	role := &goose.Role{}
+	if err = decodeJSON(res.Body, role); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// List returns every role, sorted by name
+func (sv *RolesService) List() ([]goose.Role, error) {
+	url, err := sv.s.url("/roles", nil)
+	if err != nil {
+		return nil, err
+	}
+	roles := []goose.Role{}
+	if err = sv.s.getInto(url, &roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (sv *RolesService) Retrieve(roleID string) (*goose.Role, error) {
+	if !goose.ValidObjectID(roleID) {
+		return nil, goose.ErrInvalidIDFormat
+	}
+	url, err := sv.s.url("/roles/"+roleID, nil)
+	if err != nil {
+		return nil, err
+	}
+	role := &goose.Role{}
+	if err = sv.s.getInto(url, role); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// Describe changes the description of a role. Roles can not be renamed
+func (sv *RolesService) Describe(roleID, description string) (*goose.Role, error) {
+	if !goose.ValidObjectID(roleID) {
+		return nil, goose.ErrInvalidIDFormat
+	}
+	url, err := sv.s.url("/roles/"+roleID, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := sv.s.sendJSON("PUT", url, dict{"Description": description})
+	if err != nil {
+		return nil, err
+	}
+	role := &goose.Role{}
+	if err = decodeJSON(res.Body, role); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// Delete deletes a role. Roles given to some user can not be deleted
+func (sv *RolesService) Delete(roleID string) error {
+	if !goose.ValidObjectID(roleID) {
+		return goose.ErrInvalidIDFormat
+	}
+	url, err := sv.s.url("/roles/"+roleID, nil)
+	if err != nil {
+		return err
+	}
+	_, err = sv.s.delete(url)
+	return err
+}
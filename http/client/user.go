@@ -0,0 +1,111 @@
+package client
+
+import (
+	"github.com/syb-devs/goose"
+)
+
+type UsersService struct {
+	s *Service
+}
+
+func NewUsersService(s *Service) *UsersService {
+	return &UsersService{s: s}
+}
+
+// UserChanges holds the changes to apply to a user. Only the non-nil fields are changed, so an empty slice
+// clears the roles, groups or buckets
+type UserChanges struct {
+	Subject  *string `json:",omitempty"`
+	Name     *string `json:",omitempty"`
+	Email    *string `json:",omitempty"`
+	Roles    []string
+	Groups   []string
+	Buckets  []string
+	Disabled *bool `json:",omitempty"`
+}
+
+// Create creates a user. It requires the admin role
+func (sv *UsersService) Create(user *UserChanges) (*goose.User, error) {
+	url, err := sv.s.url("/users", nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := sv.s.sendJSON("POST", url, user)
+	if err != nil {
+		return nil, err
+	}
+	created := &goose.User{}
+	if err = decodeJSON(res.Body, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// List returns every user, sorted by subject
+func (sv *UsersService) List() ([]goose.User, error) {
+	url, err := sv.s.url("/users", nil)
+	if err != nil {
+		return nil, err
+	}
+	users := []goose.User{}
+	if err = sv.s.getInto(url, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (sv *UsersService) Retrieve(userID string) (*goose.User, error) {
+	if !goose.ValidObjectID(userID) {
+		return nil, goose.ErrInvalidIDFormat
+	}
+	return sv.retrieve("/users/" + userID)
+}
+
+// Me returns the authenticated user, with the roles, groups and buckets the server uses for access control
+func (sv *UsersService) Me() (*goose.User, error) {
+	return sv.retrieve("/users/me")
+}
+
+func (sv *UsersService) retrieve(path string) (*goose.User, error) {
+	url, err := sv.s.url(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	user := &goose.User{}
+	if err = sv.s.getInto(url, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Update applies the given changes to a user, returning the updated user
+func (sv *UsersService) Update(userID string, changes *UserChanges) (*goose.User, error) {
+	if !goose.ValidObjectID(userID) {
+		return nil, goose.ErrInvalidIDFormat
+	}
+	url, err := sv.s.url("/users/"+userID, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := sv.s.sendJSON("PUT", url, changes)
+	if err != nil {
+		return nil, err
+	}
+	user := &goose.User{}
+	if err = decodeJSON(res.Body, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (sv *UsersService) Delete(userID string) error {
+	if !goose.ValidObjectID(userID) {
+		return goose.ErrInvalidIDFormat
+	}
+	url, err := sv.s.url("/users/"+userID, nil)
+	if err != nil {
+		return err
+	}
+	_, err = sv.s.delete(url)
+	return err
+}
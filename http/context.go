@@ -1,6 +1,7 @@
 package http
 
 import (
+	"errors"
 	"log"
 	"net/http"
 
@@ -10,23 +11,28 @@ import (
 // Context holds request-specific data
 type Context struct {
 	DB        *goose.DBConn
+	Storage   goose.Storage
 	URLParams URLParams
 	User      *goose.User
 }
 
 // NewContext returns a context object to be used in the current request, with
-// a copy of the database connection
+// a copy of the database connection and storage backend session
 func newContext(r *http.Request, ps map[string]string) (*Context, error) {
 	log.Printf("%v", ps)
+	storage := goose.DefaultStorage()
+	if storage == nil {
+		return nil, errors.New("no default storage backend set")
+	}
 	return &Context{
 		DB:        goose.DefaultDBConn().Copy(),
+		Storage:   storage.Copy(),
 		URLParams: URLParams(ps),
-		User:      &goose.User{}, //TODO: retrieve user from JWT auth header
 	}, nil
 }
 
 // close closes the context, freeing resources like database connection
-// and persisting the session
+// and storage session
 func (ctx *Context) close() error {
 	if ctx == nil {
 		return nil
@@ -35,5 +41,8 @@ func (ctx *Context) close() error {
 	if ctx.DB != nil {
 		ctx.DB.Close()
 	}
+	if ctx.Storage != nil {
+		ctx.Storage.Close()
+	}
 	return nil
 }
@@ -57,6 +57,11 @@ func HandlerAdapterTreeMux(f HandlerFunc) httptreemux.HandlerFunc {
 		if err != nil {
 			panic(fmt.Sprintf("error creating a new context: %v", err))
 		}
+		if ctx.User, err = authenticate(r, ctx.Storage); err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			handleError(w, r, err)
+			return
+		}
 
 		// Run the wrapped handler
 		err = f(w, r, ctx)
@@ -81,6 +86,11 @@ func HandlerAdapter(f HandlerFunc) http.HandlerFunc {
 		if err != nil {
 			panic(fmt.Sprintf("error creating a new context: %v", err))
 		}
+		if ctx.User, err = authenticate(r, ctx.Storage); err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			handleError(w, r, err)
+			return
+		}
 
 		// Run the wrapped handler
 		err = f(w, r, ctx)
@@ -152,9 +162,27 @@ func ProcessError(err error) error {
 	if err == goose.ErrNotFound {
 		return NewError(404, "Not found")
 	}
-	if err == goose.ErrInvalidIDFormat {
+	if err == goose.ErrInvalidIDFormat || err == goose.ErrInvalidCursor || err == goose.ErrInvalidSort {
 		return NewError(400, err.Error())
 	}
+	if err == goose.ErrInvalidPartNumber || err == goose.ErrInvalidPart || err == goose.ErrInvalidPartOrder {
+		return NewError(400, err.Error())
+	}
+	if err == goose.ErrDuplicateKey {
+		return NewError(409, err.Error())
+	}
+	if err == goose.ErrObjectTooLarge || err == goose.ErrUploadTooLarge {
+		return NewError(413, err.Error())
+	}
+	if err == goose.ErrOffsetMismatch {
+		return NewError(409, err.Error())
+	}
+	if err == goose.ErrUploadLocked {
+		return NewError(423, err.Error())
+	}
+	if err == goose.ErrQuotaExceeded {
+		return NewError(507, err.Error())
+	}
 	return err
 }
 
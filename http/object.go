@@ -0,0 +1,32 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/syb-devs/goose"
+)
+
+// ServeObject writes the data of an object opened for reading to the response. Range and If-Range requests
+// are supported by seeking in the object data, so only the requested parts are read from the storage.
+// Multiple ranges are sent as a multipart/byteranges response.
+//
+// The ETag (the object MD5 checksum) and Last-Modified (its upload date) headers are set, and conditional requests
+// with If-None-Match or If-Modified-Since are answered with 304 Not Modified. The Cache-Control header is set to the
+// one in the object metadata, or to the given default if empty
+func ServeObject(w http.ResponseWriter, r *http.Request, obj *goose.Object, cacheControl string) {
+	h := w.Header()
+	if obj.ContentType != "" {
+		h.Set("Content-Type", obj.ContentType)
+	}
+	if obj.MD5 != "" {
+		h.Set("ETag", strconv.Quote(obj.MD5))
+	}
+	if obj.Metadata != nil && obj.Metadata.CacheControl != "" {
+		cacheControl = obj.Metadata.CacheControl
+	}
+	if cacheControl != "" {
+		h.Set("Cache-Control", cacheControl)
+	}
+	http.ServeContent(w, r, obj.Name, obj.UploadDate, obj.File())
+}
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidPolicy is returned when an upload policy is malformed or its signature does not match
+	ErrInvalidPolicy = errors.New("invalid upload policy")
+	// ErrPolicyExpired is returned when an upload policy is used after its expiry time
+	ErrPolicyExpired = errors.New("the upload policy has expired")
+	// ErrPolicyDenied is returned when an upload does not meet the conditions of its policy
+	ErrPolicyDenied = errors.New("the upload is not allowed by the policy")
+)
+
+// UploadPolicy allows uploading objects to a bucket without credentials, under some conditions. Signed policies
+// are handed out to browsers, which send them along with the object data
+type UploadPolicy struct {
+	// Bucket is the ID of the bucket
+	Bucket string `json:"bucket"`
+	// Prefix, if set, is the prefix the object names must start with
+	Prefix string `json:"prefix,omitempty"`
+	// MaxSize, if set, is the maximum size of the objects, in bytes
+	MaxSize int64 `json:"maxSize,omitempty"`
+	// ContentTypes, if set, are the allowed content types. A type like image/* allows all its subtypes
+	ContentTypes []string `json:"contentTypes,omitempty"`
+	// Expires is the time after which the policy is no longer valid
+	Expires time.Time `json:"expires"`
+}
+
+// Sign returns the policy encoded as URL safe base64, and its signature
+func (p *UploadPolicy) Sign() (policy, signature string, err error) {
+	if len(signingKey) == 0 {
+		return "", "", ErrNoSigningKey
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return "", "", err
+	}
+	policy = base64.RawURLEncoding.EncodeToString(b)
+	return policy, policyMAC(policy), nil
+}
+
+// policyMAC returns the HMAC-SHA256 of an encoded policy, encoded as URL safe base64
+func policyMAC(policy string) string {
+	h := hmac.New(sha256.New, signingKey)
+	h.Write([]byte("POLICY\n" + policy))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// VerifyUploadPolicy checks the signature of an encoded policy, returning the policy if valid and not expired
+func VerifyUploadPolicy(policy, signature string) (*UploadPolicy, error) {
+	if len(signingKey) == 0 {
+		return nil, ErrNoSigningKey
+	}
+	if !hmac.Equal([]byte(policyMAC(policy)), []byte(signature)) {
+		return nil, ErrInvalidPolicy
+	}
+	b, err := base64.RawURLEncoding.DecodeString(policy)
+	if err != nil {
+		return nil, ErrInvalidPolicy
+	}
+	p := &UploadPolicy{}
+	if err = json.Unmarshal(b, p); err != nil {
+		return nil, ErrInvalidPolicy
+	}
+	if time.Now().After(p.Expires) {
+		return nil, ErrPolicyExpired
+	}
+	return p, nil
+}
+
+// Allows checks an upload against the policy conditions. The size is -1 if unknown
+func (p *UploadPolicy) Allows(bucketID, name, contentType string, size int64) error {
+	if bucketID != p.Bucket || !strings.HasPrefix(name, p.Prefix) {
+		return ErrPolicyDenied
+	}
+	if p.MaxSize > 0 && size > p.MaxSize {
+		return ErrPolicyDenied
+	}
+	if len(p.ContentTypes) == 0 {
+		return nil
+	}
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.TrimSpace(strings.ToLower(contentType))
+	for _, t := range p.ContentTypes {
+		if t == contentType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(contentType, t[:len(t)-1])) {
+			return nil
+		}
+	}
+	return ErrPolicyDenied
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/syb-devs/goose"
+	ghttp "github.com/syb-devs/goose/http"
+)
+
+// getBucketACL returns the access control list of a bucket
+func getBucketACL(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	bucket, err := getBucketAndCheckAccess(ctx, ctx.URLParams.ByName("bucket"), "id", goose.PermAdmin)
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	return ghttp.WriteJSON(w, 200, bucket.ACL)
+}
+
+// putBucketACL replaces the access control list of a bucket. The owner is kept if the new ACL has none
+func putBucketACL(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	bucket, err := getBucketAndCheckAccess(ctx, ctx.URLParams.ByName("bucket"), "id", goose.PermAdmin)
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	acl := goose.ACL{}
+	if err = json.NewDecoder(r.Body).Decode(&acl); err != nil {
+		return ghttp.NewError(400, "invalid ACL data")
+	}
+	if err = acl.Validate(); err != nil {
+		return ghttp.NewError(400, err.Error())
+	}
+	if acl.Owner == "" {
+		acl.Owner = bucket.ACL.Owner
+	}
+	bucket.ACL = acl
+	if err = ctx.Storage.Buckets().Update(bucket); err != nil {
+		return ghttp.ProcessError(err)
+	}
+	return ghttp.WriteJSON(w, 200, bucket.ACL)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/syb-devs/goose"
+	ghttp "github.com/syb-devs/goose/http"
+)
+
+// ErrAdminRequired represents an HTTP 403 error, returned when a user without the admin role calls an
+// administration endpoint
+var ErrAdminRequired = ghttp.NewError(403, "the admin role is required")
+
+type reqAPIKey struct {
+	Name    string
+	Owner   string
+	Roles   []string
+	Scopes  []string
+	Buckets []string
+	Expires *time.Time
+}
+
+// newAPIKey is the response body of the API key creation, the only one including the key secret
+type newAPIKey struct {
+	*goose.APIKey
+	Key string `json:"key"`
+}
+
+// postAPIKey creates an API key, owned by the given user or by the one creating it
+func postAPIKey(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	if !ctx.User.HasRole(goose.RoleAdmin) {
+		return ErrAdminRequired
+	}
+	req := &reqAPIKey{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return ghttp.NewError(400, "invalid API key data")
+	}
+	if len(req.Scopes) == 0 {
+		return ghttp.NewError(400, "missing scopes")
+	}
+	for _, scope := range req.Scopes {
+		if !goose.ValidScope(scope) {
+			return ghttp.NewError(400, goose.ErrInvalidScope.Error()+" "+scope)
+		}
+	}
+	if req.Expires != nil && req.Expires.Before(time.Now()) {
+		return ghttp.NewError(400, "invalid value for Expires, it is in the past")
+	}
+	if err := checkRoles(ctx.Storage.Roles(), req.Roles); err != nil {
+		return ghttp.ProcessError(err)
+	}
+	key := &goose.APIKey{
+		Name:    req.Name,
+		Owner:   req.Owner,
+		Roles:   req.Roles,
+		Scopes:  req.Scopes,
+		Buckets: req.Buckets,
+		Expires: req.Expires,
+	}
+	if key.Owner == "" {
+		key.Owner = ctx.User.Subject
+	}
+	if err := checkAPIKeyGrant(ctx, key); err != nil {
+		return err
+	}
+	secret, err := key.NewSecret()
+	if err != nil {
+		return err
+	}
+	if err = ctx.Storage.APIKeys().Insert(key); err != nil {
+		return ghttp.ProcessError(err)
+	}
+	w.Header().Set("Location", "/apikeys/"+key.ID.Hex())
+	return ghttp.WriteJSON(w, 201, newAPIKey{APIKey: key, Key: secret})
+}
+
+// checkAPIKeyGrant checks that the user creating a key holds everything the key grants: its roles, and access to
+// its buckets. Keys created by users restricted to some buckets get the same restriction if none is given, and
+// only admins with access to every bucket can create keys for other users
+func checkAPIKeyGrant(ctx *ghttp.Context, key *goose.APIKey) error {
+	restricted := len(ctx.User.Buckets) > 0
+	if key.Owner != ctx.User.Subject && restricted {
+		return ghttp.NewError(403, "only admins with access to every bucket can create keys for other users")
+	}
+	for _, role := range key.Roles {
+		if !ctx.User.HasRole(role) {
+			return ghttp.NewError(403, "the key can not have the role "+role+", which you do not have")
+		}
+	}
+	if !restricted {
+		return nil
+	}
+	if len(key.Buckets) == 0 {
+		key.Buckets = ctx.User.Buckets
+		return nil
+	}
+	for _, name := range key.Buckets {
+		if !canGrantBucket(ctx, name) {
+			return ghttp.NewError(403, "the key can not access the bucket "+name+", which you can not access")
+		}
+	}
+	return nil
+}
+
+// canGrantBucket tells whether the user can access the bucket with the given name or ID
+func canGrantBucket(ctx *ghttp.Context, name string) bool {
+	for _, b := range ctx.User.Buckets {
+		if b == name {
+			return true
+		}
+	}
+	repo := ctx.Storage.Buckets()
+	bucket, err := repo.FindName(name)
+	if err == goose.ErrNotFound && goose.ValidObjectID(name) {
+		bucket, err = repo.FindId(name)
+	}
+	return err == nil && ctx.User.CanAccessBucket(bucket)
+}
+
+// listAPIKeys lists the API keys, newest first. They can be filtered by owner
+func listAPIKeys(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	if !ctx.User.HasRole(goose.RoleAdmin) {
+		return ErrAdminRequired
+	}
+	keys, err := ctx.Storage.APIKeys().Find(r.URL.Query().Get("owner"))
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	return ghttp.WriteJSON(w, 200, keys)
+}
+
+func getAPIKey(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	if !ctx.User.HasRole(goose.RoleAdmin) {
+		return ErrAdminRequired
+	}
+	key, err := ctx.Storage.APIKeys().FindId(ctx.URLParams.ByName("key"))
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	return ghttp.WriteJSON(w, 200, key)
+}
+
+// deleteAPIKey revokes an API key
+func deleteAPIKey(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	if !ctx.User.HasRole(goose.RoleAdmin) {
+		return ErrAdminRequired
+	}
+	return ghttp.ProcessError(ctx.Storage.APIKeys().DeleteId(ctx.URLParams.ByName("key")))
+}
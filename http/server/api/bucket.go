@@ -3,44 +3,118 @@ package main
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"github.com/syb-devs/goose"
 	ghttp "github.com/syb-devs/goose/http"
+	"gopkg.in/mgo.v2/bson"
 )
 
 // ErrBucketExists represents an HTTP 409 error, returned when the user is trying to create a bucket,
 // but another with the same name already exists
 var ErrBucketExists = ghttp.NewError(409, "the bucket already exists")
 
+// ErrBucketNotEmpty represents an HTTP 409 error, returned when the user is trying to delete a bucket with objects
+// without forcing it
+var ErrBucketNotEmpty = ghttp.NewError(409, "the bucket is not empty, use force=true to delete its objects too")
+
 type reqBucket struct {
-	Name *string
+	Name         *string
+	Versioning   *bool
+	Quota        *goose.BucketQuota
+	Visibility   *goose.Visibility
+	CacheControl *string
 }
 
 func (b *reqBucket) Apply(bucket *goose.Bucket) {
 	if b.Name != nil {
 		bucket.Name = *b.Name
 	}
+	if b.Versioning != nil {
+		bucket.Versioning = *b.Versioning
+	}
+	if b.Quota != nil {
+		bucket.Quota = *b.Quota
+	}
+	if b.Visibility != nil {
+		bucket.Visibility = *b.Visibility
+	}
+	if b.CacheControl != nil {
+		bucket.CacheControl = *b.CacheControl
+	}
+}
+
+// Validate checks the values given for the bucket fields
+func (b *reqBucket) Validate() error {
+	if b.Name != nil && *b.Name == "" {
+		return ghttp.NewError(400, "the bucket name can not be empty")
+	}
+	if b.Visibility != nil && !b.Visibility.Valid() {
+		return ghttp.NewError(400, "invalid visibility, use public, authenticated or private")
+	}
+	return nil
 }
 
 func postBucket(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
-	//TODO: validation of the POSTed data
-	repo := goose.NewBucketRepo(ctx.DB)
+	repo := ctx.Storage.Buckets()
 	reqBucket, err := bucketFromRequest(*r)
 	if err != nil {
 		return err
 	}
+	if reqBucket.Name == nil {
+		return ghttp.NewError(400, "missing bucket name")
+	}
+	if err = reqBucket.Validate(); err != nil {
+		return err
+	}
 	if repo.Exists(*reqBucket.Name) {
 		return ErrBucketExists
 	}
-	bucket := &goose.Bucket{}
+	bucket := &goose.Bucket{ACL: goose.ACL{Owner: ctx.User.Subject}}
 	reqBucket.Apply(bucket)
 	err = repo.Insert(bucket)
+	if err == goose.ErrDuplicateKey {
+		// Created by another request since checking
+		return ErrBucketExists
+	}
 	if err != nil {
-		return err
+		return ghttp.ProcessError(err)
 	}
 	return ghttp.WriteJSON(w, 201, bucket)
 }
 
+// bucketListPage is the response body of the bucket listing
+type bucketListPage struct {
+	Buckets []*goose.Bucket `json:"buckets"`
+	Next    string          `json:"next,omitempty"`
+}
+
+// listBuckets lists the buckets the user can read, sorted by name
+func listBuckets(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	query := r.URL.Query()
+	opts := goose.BucketListOptions{
+		Limit:  defaultListLimit,
+		Prefix: query.Get("prefix"),
+		Cursor: query.Get("cursor"),
+		Filter: ctx.User.CanReadBucket,
+	}
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			return ghttp.NewError(400, "invalid value for limit")
+		}
+		opts.Limit = limit
+	}
+	if opts.Limit > maxListLimit {
+		opts.Limit = maxListLimit
+	}
+	blist, err := ctx.Storage.Buckets().Find(opts)
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	return ghttp.WriteJSON(w, 200, bucketListPage{Buckets: blist.Buckets(), Next: blist.Next()})
+}
+
 func getBucket(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
 	return _getBucket("id", w, r, ctx)
 }
@@ -53,7 +127,7 @@ func _getBucket(key string, w http.ResponseWriter, r *http.Request, ctx *ghttp.C
 	var err error
 	var bucket *goose.Bucket
 
-	repo := goose.NewBucketRepo(ctx.DB)
+	repo := ctx.Storage.Buckets()
 
 	if key == "id" {
 		bucket, err = repo.FindId(ctx.URLParams.ByName("bucket"))
@@ -71,20 +145,21 @@ func _getBucket(key string, w http.ResponseWriter, r *http.Request, ctx *ghttp.C
 }
 
 func putBucket(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
-	repo := goose.NewBucketRepo(ctx.DB)
+	repo := ctx.Storage.Buckets()
 	bucket, err := repo.FindId(ctx.URLParams.ByName("bucket"))
 	if err != nil {
 		return ghttp.ProcessError(err)
 	}
-	if !ctx.User.CanWriteBucket(bucket) {
+	if !ctx.User.Can(bucket, goose.PermAdmin) {
 		return ghttp.ErrForbidden
 	}
-	dec := json.NewDecoder(r.Body)
-	var reqBucket *reqBucket
-	err = dec.Decode(reqBucket)
+	reqBucket, err := bucketFromRequest(*r)
 	if err != nil {
 		return err
 	}
+	if err = reqBucket.Validate(); err != nil {
+		return err
+	}
 	reqBucket.Apply(bucket)
 
 	if err = repo.Update(bucket); err != nil {
@@ -94,21 +169,83 @@ func putBucket(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error
 }
 
 func deleteBucket(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
-	repo := goose.NewBucketRepo(ctx.DB)
+	repo := ctx.Storage.Buckets()
 	bucket, err := repo.FindId(ctx.URLParams.ByName("bucket"))
 	if err != nil {
 		return ghttp.ProcessError(err)
 	}
-	if !ctx.User.CanWriteBucket(bucket) {
+	if !ctx.User.Can(bucket, goose.PermAdmin) {
 		return ghttp.ErrForbidden
 	}
-	return ghttp.ProcessError(repo.DeleteId(ctx.URLParams.ByName("bucket")))
+
+	page, err := ctx.Storage.Objects().FindByBucket(bucket.ID, goose.ListOptions{Limit: 1})
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	page.Close()
+	empty := len(page.Objects()) == 0
+	if !empty && r.URL.Query().Get("force") != "true" {
+		return ErrBucketNotEmpty
+	}
+
+	// Once the bucket is gone no more objects can be uploaded to it, so its objects are purged afterwards
+	if err = repo.DeleteId(bucket.ID.Hex()); err != nil {
+		return ghttp.ProcessError(err)
+	}
+	if empty {
+		return nil
+	}
+	j := startJob("bucket-delete", bucket, bucket.Objects, func(j *job) error {
+		return purgeBucketObjects(j, bucket.ID)
+	})
+	w.Header().Set("Location", "/jobs/"+j.ID.Hex())
+	return ghttp.WriteJSON(w, 202, j.snapshot())
+}
+
+// purgeBucketObjects deletes every object stored in a bucket, data included
+func purgeBucketObjects(j *job, bucketID bson.ObjectId) error {
+	storage := goose.DefaultStorage().Copy()
+	defer storage.Close()
+
+	repo := storage.Objects()
+	for {
+		page, err := repo.FindByBucket(bucketID, goose.ListOptions{Limit: 100})
+		if err != nil {
+			return err
+		}
+		page.Close()
+		if len(page.Objects()) == 0 {
+			return nil
+		}
+		for _, object := range page.Objects() {
+			if err = repo.DeleteId(object.ID.Hex()); err != nil && err != goose.ErrNotFound {
+				return err
+			}
+			j.progress(1)
+		}
+	}
+}
+
+// recomputeBucketStats repairs the object count and total size of a bucket, in case they have drifted
+func recomputeBucketStats(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	bucket, err := getBucketAndCheckAccess(ctx, ctx.URLParams.ByName("bucket"), "id", goose.PermAdmin)
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	if err = ctx.Storage.Objects().RecomputeBucketStats(bucket.ID); err != nil {
+		return ghttp.ProcessError(err)
+	}
+	bucket, err = ctx.Storage.Buckets().FindId(bucket.ID.Hex())
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	return ghttp.WriteJSON(w, 200, bucket)
 }
 
 func bucketFromRequest(r http.Request) (*reqBucket, error) {
 	bucket := &reqBucket{}
-	dec := json.NewDecoder(r.Body)
-	err := dec.Decode(bucket)
-
-	return bucket, err
+	if err := json.NewDecoder(r.Body).Decode(bucket); err != nil {
+		return nil, ghttp.NewError(400, "invalid bucket data")
+	}
+	return bucket, nil
 }
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/syb-devs/goose"
+	ghttp "github.com/syb-devs/goose/http"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+
+	// Finished jobs are forgotten after this time
+	jobRetention = 24 * time.Hour
+)
+
+// job tracks the progress of a task run in the background on a bucket. Jobs are kept in the memory of the API
+// server process that started them, so they are lost on restart and, behind a load balancer, only that instance
+// knows about them
+type job struct {
+	mu       sync.Mutex
+	ID       bson.ObjectId `json:"id"`
+	Type     string        `json:"type"`
+	BucketID bson.ObjectId `json:"bucketId"`
+	Status   string        `json:"status"`
+	Total    int           `json:"total"`
+	Done     int           `json:"done"`
+	Error    string        `json:"error,omitempty"`
+	Started  time.Time     `json:"started"`
+	Finished *time.Time    `json:"finished,omitempty"`
+	// bucket is a copy of the bucket taken when the job started, to check who can see the job once the bucket is
+	// gone
+	bucket *goose.Bucket
+}
+
+var jobs = struct {
+	sync.RWMutex
+	m map[bson.ObjectId]*job
+}{m: make(map[bson.ObjectId]*job)}
+
+// startJob registers a new job on a bucket and runs the task in the background
+func startJob(jobType string, bucket *goose.Bucket, total int, task func(j *job) error) *job {
+	b := *bucket
+	j := &job{
+		ID:       bson.NewObjectId(),
+		Type:     jobType,
+		BucketID: bucket.ID,
+		Status:   jobRunning,
+		Total:    total,
+		Started:  time.Now(),
+		bucket:   &b,
+	}
+
+	jobs.Lock()
+	for id, old := range jobs.m {
+		if finished := old.snapshot().Finished; finished != nil && time.Since(*finished) > jobRetention {
+			delete(jobs.m, id)
+		}
+	}
+	jobs.m[j.ID] = j
+	jobs.Unlock()
+
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				goose.Log.Critical(fmt.Sprintf("recovering from panic in job %s: %v. \nstack trace: %s", j.ID.Hex(), p, debug.Stack()))
+				j.finish(jobFailed, fmt.Sprintf("%v", p))
+			}
+		}()
+		if err := task(j); err != nil {
+			goose.Log.Error(fmt.Sprintf("job %s failed: %v", j.ID.Hex(), err))
+			j.finish(jobFailed, err.Error())
+			return
+		}
+		j.finish(jobDone, "")
+	}()
+	return j
+}
+
+// progress adds n units of work to the job done count
+func (j *job) progress(n int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.Done += n
+	if j.Done > j.Total {
+		j.Total = j.Done
+	}
+}
+
+func (j *job) finish(status, errMsg string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	j.Status = status
+	j.Error = errMsg
+	j.Finished = &now
+}
+
+// snapshot returns a copy of the job, safe to read while the job goes on
+func (j *job) snapshot() *job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return &job{
+		ID:       j.ID,
+		Type:     j.Type,
+		BucketID: j.BucketID,
+		Status:   j.Status,
+		Total:    j.Total,
+		Done:     j.Done,
+		Error:    j.Error,
+		Started:  j.Started,
+		Finished: j.Finished,
+	}
+}
+
+// getJob returns the progress of a job, to the users allowed to administer its bucket
+func getJob(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	id := ctx.URLParams.ByName("job")
+	if !goose.ValidObjectID(id) {
+		return ghttp.ProcessError(goose.ErrInvalidIDFormat)
+	}
+	jobs.RLock()
+	j, ok := jobs.m[bson.ObjectIdHex(id)]
+	jobs.RUnlock()
+
+	if !ok {
+		return ghttp.NewError(404, "")
+	}
+	if !ctx.User.Can(j.bucket, goose.PermAdmin) {
+		return ghttp.ErrForbidden
+	}
+	return ghttp.WriteJSON(w, 200, j.snapshot())
+}
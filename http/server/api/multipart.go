@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/syb-devs/goose"
+	ghttp "github.com/syb-devs/goose/http"
+)
+
+// multiparts keeps the parts of the multipart uploads until they are complete
+var multiparts *goose.MultipartStore
+
+// reqMultipartUpload is the request body to start a multipart upload
+type reqMultipartUpload struct {
+	Name        string
+	ContentType string
+	Metadata    *reqObjectMetadata
+}
+
+// multipartUploadStatus is a multipart upload along with its uploaded parts
+type multipartUploadStatus struct {
+	*goose.MultipartUpload
+	Parts []*goose.Part `json:"parts"`
+}
+
+// postMultipartUpload starts a multipart upload of an object, whose parts can then be uploaded concurrently
+func postMultipartUpload(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	bucket, err := getBucketAndCheckAccess(ctx, ctx.URLParams.ByName("bucket"), "id", goose.PermWrite)
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	req := &reqMultipartUpload{}
+	if err = json.NewDecoder(r.Body).Decode(req); err != nil {
+		return ghttp.NewError(400, "invalid JSON body")
+	}
+	if req.Name == "" {
+		return ghttp.NewError(400, "missing object name")
+	}
+	upload := &goose.MultipartUpload{
+		BucketID:    bucket.ID,
+		Name:        ghttp.PrefixSlash(req.Name),
+		ContentType: req.ContentType,
+	}
+	if req.Metadata != nil {
+		req.Metadata.Apply(&upload.Metadata)
+	}
+	if ctx.User != nil {
+		upload.Owner = ctx.User.Subject
+	}
+	if err = multiparts.Initiate(upload); err != nil {
+		return err
+	}
+	return ghttp.WriteJSON(w, 201, upload)
+}
+
+// findMultipartUpload returns the multipart upload in the URL, which must belong to a bucket where the user can
+// upload objects, and have been started by the user
+func findMultipartUpload(ctx *ghttp.Context) (*goose.Bucket, *goose.MultipartUpload, error) {
+	bucket, err := getBucketAndCheckAccess(ctx, ctx.URLParams.ByName("bucket"), "id", goose.PermWrite)
+	if err != nil {
+		return nil, nil, ghttp.ProcessError(err)
+	}
+	upload, err := multiparts.Find(ctx.URLParams.ByName("upload"))
+	if err != nil {
+		return nil, nil, ghttp.ProcessError(err)
+	}
+	if upload.BucketID != bucket.ID {
+		return nil, nil, ghttp.NewError(404, "")
+	}
+	if ctx.User != nil && upload.Owner != ctx.User.Subject && !ctx.User.HasRole(goose.RoleAdmin) {
+		return nil, nil, ghttp.NewError(404, "")
+	}
+	return bucket, upload, nil
+}
+
+// getMultipartUpload returns a multipart upload along with its uploaded parts
+func getMultipartUpload(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	_, upload, err := findMultipartUpload(ctx)
+	if err != nil {
+		return err
+	}
+	parts, err := multiparts.Parts(upload.ID)
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	return ghttp.WriteJSON(w, 200, multipartUploadStatus{MultipartUpload: upload, Parts: parts})
+}
+
+// putUploadPart uploads a part of a multipart upload with the request body, replacing the part with the same
+// number. The part MD5 checksum is returned in the ETag header
+func putUploadPart(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	bucket, upload, err := findMultipartUpload(ctx)
+	if err != nil {
+		return err
+	}
+	number, err := strconv.Atoi(ctx.URLParams.ByName("part"))
+	if err != nil {
+		return ghttp.ProcessError(goose.ErrInvalidPartNumber)
+	}
+	data, err := goose.LimitReader(r.Body, r.ContentLength, bucket.Quota.MaxObjectSize)
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	part, err := multiparts.PutPart(upload.ID, number, data)
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	w.Header().Set("ETag", strconv.Quote(part.MD5))
+	return ghttp.WriteJSON(w, 200, part)
+}
+
+// reqCompleteMultipartUpload is the request body to complete a multipart upload
+type reqCompleteMultipartUpload struct {
+	Parts []goose.Part
+}
+
+// completeMultipartUpload joins the given parts of a multipart upload, in ascending order of their numbers, into
+// the object. Their checksums are checked if given. Without parts, all the uploaded ones are joined
+func completeMultipartUpload(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	bucket, upload, err := findMultipartUpload(ctx)
+	if err != nil {
+		return err
+	}
+	req := &reqCompleteMultipartUpload{}
+	if r.ContentLength != 0 {
+		if err = json.NewDecoder(r.Body).Decode(req); err != nil {
+			return ghttp.NewError(400, "invalid JSON body")
+		}
+	}
+	if len(req.Parts) == 0 {
+		uploaded, err := multiparts.Parts(upload.ID)
+		if err != nil {
+			return ghttp.ProcessError(err)
+		}
+		for _, p := range uploaded {
+			req.Parts = append(req.Parts, *p)
+		}
+	}
+	data, size, err := multiparts.Open(upload.ID, req.Parts)
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	defer data.Close()
+
+	object, err := goose.PutObject(ctx.Storage.Objects(), bucket, data, size, upload.Name, upload.ContentType, &upload.Metadata)
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	if err = multiparts.Remove(upload.ID); err != nil {
+		goose.Log.Error(fmt.Sprintf("error removing the completed multipart upload %s: %v", upload.ID, err))
+	}
+	return ghttp.WriteJSON(w, 201, object)
+}
+
+// abortMultipartUpload discards a multipart upload along with its parts
+func abortMultipartUpload(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	_, upload, err := findMultipartUpload(ctx)
+	if err != nil {
+		return err
+	}
+	if err = multiparts.Remove(upload.ID); err != nil {
+		return ghttp.ProcessError(err)
+	}
+	w.WriteHeader(204)
+	return nil
+}
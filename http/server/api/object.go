@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/syb-devs/goose"
@@ -12,11 +14,16 @@ import (
 	"gopkg.in/mgo.v2/bson"
 )
 
+// ErrObjectExists represents an HTTP 412 error, returned when uploading an object with the If-None-Match: * header,
+// but another with the same name already exists
+var ErrObjectExists = ghttp.NewError(412, "the object already exists")
+
 type reqObjectMetadata struct {
-	Title       *string
-	Description *string
-	Tags        []string
-	Custom      map[string]interface{}
+	Title        *string
+	Description  *string
+	CacheControl *string
+	Tags         []string
+	Custom       map[string]interface{}
 }
 
 func (m *reqObjectMetadata) Apply(meta *goose.ObjectMetadata) {
@@ -26,38 +33,86 @@ func (m *reqObjectMetadata) Apply(meta *goose.ObjectMetadata) {
 	if m.Description != nil {
 		meta.Description = *m.Description
 	}
+	if m.CacheControl != nil {
+		meta.CacheControl = *m.CacheControl
+	}
 	if m.Tags != nil {
 		meta.Tags = m.Tags
 	}
+	if len(m.Custom) > 0 && meta.Custom == nil {
+		meta.Custom = make(map[string]interface{}, len(m.Custom))
+	}
 	for k, v := range m.Custom {
 		meta.Custom[k] = v
 	}
 }
 
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// objectListPage is the response body of the object listings
+type objectListPage struct {
+	Objects  []*goose.Object `json:"objects"`
+	Prefixes []string        `json:"prefixes,omitempty"`
+	Next     string          `json:"next,omitempty"`
+}
+
 func listObjects(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
 	bucketID := ctx.URLParams.ByName("bucket")
 
-	bucket, err := getBucketAndCheckAccess(ctx, bucketID, "id", "read")
+	bucket, err := getBucketAndCheckAccess(ctx, bucketID, "id", goose.PermRead)
 	if err != nil {
 		return ghttp.ProcessError(err)
 	}
-	repo := goose.NewObjectRepo(ctx.DB)
-	olist, err := repo.FindByBucket(bucket.ID, 0, 100)
+	opts, err := listOptionsFromRequest(r)
+	if err != nil {
+		return err
+	}
+	repo := ctx.Storage.Objects()
+	olist, err := repo.FindByBucket(bucket.ID, opts)
 	if err != nil {
 		return ghttp.ProcessError(err)
 	}
 	defer olist.Close()
-	return ghttp.WriteJSON(w, 200, olist.Objects())
+	return ghttp.WriteJSON(w, 200, objectListPage{
+		Objects:  olist.Objects(),
+		Prefixes: olist.Prefixes(),
+		Next:     olist.Next(),
+	})
+}
+
+func listOptionsFromRequest(r *http.Request) (goose.ListOptions, error) {
+	query := r.URL.Query()
+	opts := goose.ListOptions{
+		Limit:     defaultListLimit,
+		Sort:      query.Get("sort"),
+		Cursor:    query.Get("cursor"),
+		Prefix:    query.Get("prefix"),
+		Delimiter: query.Get("delimiter"),
+	}
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			return opts, ghttp.NewError(400, "invalid value for limit")
+		}
+		opts.Limit = limit
+	}
+	if opts.Limit > maxListLimit {
+		opts.Limit = maxListLimit
+	}
+	return opts, nil
 }
 
 func listObjectsByIds(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
 	bucketID := ctx.URLParams.ByName("bucket")
 
-	_, err := getBucketAndCheckAccess(ctx, bucketID, "id", "read")
+	bucket, err := getBucketAndCheckAccess(ctx, bucketID, "id", goose.PermRead)
 	if err != nil {
 		return ghttp.ProcessError(err)
 	}
-	repo := goose.NewObjectRepo(ctx.DB)
+	repo := ctx.Storage.Objects()
 
 	ids := strings.Split(ctx.URLParams.ByName("objects"), ",")
 
@@ -66,15 +121,40 @@ func listObjectsByIds(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context
 		return ghttp.ProcessError(err)
 	}
 	defer oList.Close()
-	return ghttp.WriteJSON(w, 200, oList.Objects())
+	// Leave out the objects from other buckets
+	objects := []*goose.Object{}
+	for _, object := range oList.Objects() {
+		if object.Metadata.BucketID == bucket.ID {
+			objects = append(objects, object)
+		}
+	}
+	return ghttp.WriteJSON(w, 200, objects)
 }
 
+// postObject uploads an object to a bucket. The user needs write permission in the bucket, unless the request has
+// a signed upload policy allowing it
 func postObject(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
-	fname := ghttp.PrefixSlash(r.URL.Query().Get("name"))
 	bucketID := ctx.URLParams.ByName("bucket")
+	policy, err := uploadPolicyFromRequest(r)
+	if err != nil {
+		return err
+	}
+	name := formOrQuery(r, "name")
+	if name == "" {
+		return ghttp.NewError(400, "missing object name")
+	}
+	fname := ghttp.PrefixSlash(name)
 	goose.Log.Debug(fmt.Sprintf("posting object to bucket %s with path %s", bucketID, fname))
 
-	bucket, err := getBucketAndCheckAccess(ctx, bucketID, "id", "write")
+	var bucket *goose.Bucket
+	if policy != nil {
+		bucket, err = ctx.Storage.Buckets().FindId(bucketID)
+	} else if ctx.User == nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		return ghttp.ErrUnauthorized
+	} else {
+		bucket, err = getBucketAndCheckAccess(ctx, bucketID, "id", goose.PermWrite)
+	}
 	if err != nil {
 		return ghttp.ProcessError(err)
 	}
@@ -83,39 +163,59 @@ func postObject(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) erro
 	if r.URL.Query().Get("uploaderID") != "" {
 		meta.UploaderID = bson.ObjectIdHex(r.URL.Query().Get("uploaderID"))
 	}
-	repo := goose.NewObjectRepo(ctx.DB)
+	repo := ctx.Storage.Objects()
 
-	var object *goose.Object
+	if r.Header.Get("If-None-Match") == "*" {
+		// The client does not want to overwrite an existing object
+		versions, err := repo.FindVersions(bucket.ID, fname)
+		if err != nil {
+			return ghttp.ProcessError(err)
+		}
+		versions.Close()
+		if len(versions.Objects()) > 0 {
+			return ErrObjectExists
+		}
+	}
 
+	var data io.Reader
+	var contentType string
+	var size int64
 	f, fi, err := r.FormFile("object")
 	if err == nil {
 		// Use the file in the "object" form field
 		defer f.Close()
-		object, err = repo.Create(f, fname, fi.Header.Get("Content-Type"), meta)
+		data, contentType, size = f, fi.Header.Get("Content-Type"), fi.Size
 	} else {
 		// Use the request body as file data (the RESTful way)
-		object, err = repo.Create(r.Body, fname, r.Header.Get("Content-Type"), meta)
+		data, contentType, size = r.Body, r.Header.Get("Content-Type"), r.ContentLength
+	}
+	if policy != nil {
+		if err = policy.Allows(bucket.ID.Hex(), fname, contentType, size); err != nil {
+			return ghttp.NewError(403, err.Error())
+		}
+		if policy.MaxSize > 0 {
+			if data, err = goose.LimitReader(data, size, policy.MaxSize); err != nil {
+				return ghttp.ProcessError(err)
+			}
+		}
 	}
-	defer object.GridFile().Close()
 
-	resObj, err := repo.OpenId(object.GetID().Hex())
+	object, err := goose.PutObject(repo, bucket, data, size, fname, contentType, meta)
 	if err != nil {
-		return err
+		return ghttp.ProcessError(err)
 	}
-	defer resObj.GridFile().Close()
-
-	return ghttp.WriteJSON(w, 201, resObj)
+	return ghttp.WriteJSON(w, 201, object)
 }
 
 func getObject(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
 	bucketID := ctx.URLParams.ByName("bucket")
-	bucket, err := getBucketAndCheckAccess(ctx, bucketID, "id", "read")
+	bucket, err := getBucketAndCheckAccess(ctx, bucketID, "id", goose.PermRead)
 	if err != nil {
 		return ghttp.ProcessError(err)
 	}
 
-	repo := goose.NewObjectRepo(ctx.DB)
-	object, err := repo.OpenId(ctx.URLParams.ByName("object"))
+	repo := ctx.Storage.Objects()
+	object, err := repo.FindId(ctx.URLParams.ByName("object"))
 	if err != nil {
 		return ghttp.ProcessError(err)
 	}
@@ -125,9 +225,38 @@ func getObject(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error
 	return ghttp.WriteJSON(w, 200, object)
 }
 
+// getObjectData streams the data of an object
+func getObjectData(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	bucket, err := getBucketAndCheckAccess(ctx, ctx.URLParams.ByName("bucket"), "id", goose.PermRead)
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	object, err := ctx.Storage.Objects().OpenId(ctx.URLParams.ByName("object"))
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	defer object.Close()
+	if object.Metadata.BucketID != bucket.ID {
+		return ghttp.NewError(404, "")
+	}
+	ghttp.ServeObject(w, r, object, bucket.CacheControl)
+	return nil
+}
+
 func deleteObject(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
-	repo := goose.NewObjectRepo(ctx.DB)
-	return repo.DeleteId(ctx.URLParams.ByName("object"))
+	bucket, err := getBucketAndCheckAccess(ctx, ctx.URLParams.ByName("bucket"), "id", goose.PermDelete)
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	repo := ctx.Storage.Objects()
+	object, err := repo.FindId(ctx.URLParams.ByName("object"))
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	if object.Metadata.BucketID != bucket.ID {
+		return ghttp.NewError(404, "")
+	}
+	return ghttp.ProcessError(repo.DeleteId(object.ID.Hex()))
 }
 
 func objectFromRequest(r http.Request) (*goose.Object, error) {
@@ -139,11 +268,18 @@ func objectFromRequest(r http.Request) (*goose.Object, error) {
 }
 
 func putObjectMetadata(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
-	repo := goose.NewObjectRepo(ctx.DB)
-	object, err := repo.OpenId(ctx.URLParams.ByName("object"))
+	bucket, err := getBucketAndCheckAccess(ctx, ctx.URLParams.ByName("bucket"), "id", goose.PermWrite)
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	repo := ctx.Storage.Objects()
+	object, err := repo.FindId(ctx.URLParams.ByName("object"))
 	if err != nil {
 		return ghttp.ProcessError(err)
 	}
+	if object.Metadata.BucketID != bucket.ID {
+		return ghttp.NewError(404, "")
+	}
 	reqMeta := &reqObjectMetadata{}
 	dec := json.NewDecoder(r.Body)
 	err = dec.Decode(reqMeta)
@@ -152,11 +288,15 @@ func putObjectMetadata(w http.ResponseWriter, r *http.Request, ctx *ghttp.Contex
 	}
 	meta := object.Metadata
 	reqMeta.Apply(meta)
-	return repo.UpdateMetada(object.ID.Hex(), object.Name, *meta)
+	if err = repo.UpdateMetadata(object.ID.Hex(), object.Name, *meta); err != nil {
+		return ghttp.ProcessError(err)
+	}
+	return ghttp.WriteJSON(w, 200, object)
 }
 
-func getBucketAndCheckAccess(ctx *ghttp.Context, key, keyType, op string) (*goose.Bucket, error) {
-	br := goose.NewBucketRepo(ctx.DB)
+// getBucketAndCheckAccess finds a bucket by name or ID, checking that the user is allowed the given operation in it
+func getBucketAndCheckAccess(ctx *ghttp.Context, key, keyType string, perm goose.Permission) (*goose.Bucket, error) {
+	br := ctx.Storage.Buckets()
 	var err error
 	var bucket *goose.Bucket
 
@@ -172,18 +312,8 @@ func getBucketAndCheckAccess(ctx *ghttp.Context, key, keyType, op string) (*goos
 	if err != nil {
 		return bucket, err
 	}
-
-	switch op {
-	case "read":
-		if !ctx.User.CanWriteBucket(bucket) {
-			return bucket, ghttp.ErrForbidden
-		}
-	case "write":
-		if !ctx.User.CanWriteBucket(bucket) {
-			return bucket, ghttp.ErrForbidden
-		}
-	default:
-		return bucket, errors.New("invalid value for op (accepted are 'read' and 'write'")
+	if !ctx.User.Can(bucket, perm) {
+		return bucket, ghttp.ErrForbidden
 	}
 	return bucket, nil
 }
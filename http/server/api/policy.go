@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/syb-devs/goose"
+	ghttp "github.com/syb-devs/goose/http"
+)
+
+const (
+	defaultPolicyExpiry = time.Hour
+	maxPolicyExpiry     = 7 * 24 * time.Hour
+)
+
+type reqUploadPolicy struct {
+	// Expires is the number of seconds the policy is valid for
+	Expires      int
+	Prefix       string
+	MaxSize      int64
+	ContentTypes []string
+}
+
+// uploadPolicy is the response body of the upload policy endpoint. Browsers upload the objects to the URL,
+// adding the name query parameter, or send the policy and signature as form fields along with the object
+type uploadPolicy struct {
+	Policy    string    `json:"policy"`
+	Signature string    `json:"signature"`
+	URL       string    `json:"url"`
+	Expires   time.Time `json:"expires"`
+}
+
+// postUploadPolicy issues a signed policy to upload objects to a bucket without credentials
+func postUploadPolicy(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	bucket, err := getBucketAndCheckAccess(ctx, ctx.URLParams.ByName("bucket"), "id", goose.PermWrite)
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	req := &reqUploadPolicy{}
+	if err = json.NewDecoder(r.Body).Decode(req); err != nil {
+		return ghttp.NewError(400, "invalid upload policy data")
+	}
+	expiry := defaultPolicyExpiry
+	if req.Expires != 0 {
+		expiry = time.Duration(req.Expires) * time.Second
+	}
+	if expiry <= 0 || expiry > maxPolicyExpiry {
+		return ghttp.NewError(400, "invalid value for Expires, use up to 7 days")
+	}
+	if req.MaxSize < 0 {
+		return ghttp.NewError(400, "invalid value for MaxSize")
+	}
+	policy := &ghttp.UploadPolicy{
+		Bucket:  bucket.ID.Hex(),
+		MaxSize: req.MaxSize,
+		Expires: time.Now().Add(expiry).Truncate(time.Second),
+	}
+	if req.Prefix != "" {
+		policy.Prefix = ghttp.PrefixSlash(req.Prefix)
+	}
+	for _, t := range req.ContentTypes {
+		t = strings.ToLower(t)
+		if _, _, err = mime.ParseMediaType(t); err != nil {
+			return ghttp.NewError(400, "invalid content type "+t)
+		}
+		policy.ContentTypes = append(policy.ContentTypes, t)
+	}
+
+	encoded, signature, err := policy.Sign()
+	if err != nil {
+		return err
+	}
+	query := url.Values{"policy": {encoded}, "signature": {signature}}
+	return ghttp.WriteJSON(w, 201, uploadPolicy{
+		Policy:    encoded,
+		Signature: signature,
+		URL:       "/buckets/" + bucket.ID.Hex() + "/objects?" + query.Encode(),
+		Expires:   policy.Expires,
+	})
+}
+
+// uploadPolicyFromRequest returns the upload policy sent in the query or form fields, or nil if there is none
+func uploadPolicyFromRequest(r *http.Request) (*ghttp.UploadPolicy, error) {
+	policy := formOrQuery(r, "policy")
+	if policy == "" {
+		return nil, nil
+	}
+	p, err := ghttp.VerifyUploadPolicy(policy, formOrQuery(r, "signature"))
+	if err != nil {
+		return nil, ghttp.NewError(403, err.Error())
+	}
+	return p, nil
+}
+
+// formOrQuery returns the value of a query parameter, or of a field of multipart forms if it is not in the query
+func formOrQuery(r *http.Request, key string) string {
+	if v := r.URL.Query().Get(key); v != "" {
+		return v
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.FormValue(key)
+	}
+	return ""
+}
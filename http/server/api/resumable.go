@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/syb-devs/goose"
+	ghttp "github.com/syb-devs/goose/http"
+)
+
+const (
+	// tusVersion is the version of the tus resumable upload protocol implemented by the server
+	tusVersion = "1.0.0"
+	// tusExtensions are the extensions of the tus protocol supported by the server
+	tusExtensions = "creation,creation-with-upload,termination,expiration"
+	// tusContentType is the content type of the requests with upload data
+	tusContentType = "application/offset+octet-stream"
+)
+
+var (
+	// resumables keeps the data of the resumable uploads until they are complete
+	resumables *goose.ResumableStore
+	// uploadExpiry is how long the incomplete resumable and multipart uploads are kept
+	uploadExpiry time.Duration
+)
+
+// tus wraps a handler of the tus resumable upload protocol, refusing the requests for other versions of it
+func tus(f ghttp.HandlerFunc) ghttp.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+		w.Header().Set("Tus-Resumable", tusVersion)
+		if r.Header.Get("Tus-Resumable") != tusVersion {
+			w.Header().Set("Tus-Version", tusVersion)
+			return ghttp.NewError(412, "unsupported tus version, use "+tusVersion)
+		}
+		return f(w, r, ctx)
+	}
+}
+
+// optionsResumableUpload tells the tus clients the protocol version and extensions supported by the server
+func optionsResumableUpload(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", tusExtensions)
+	w.WriteHeader(204)
+	return nil
+}
+
+// postResumableUpload creates a resumable upload of an object with the length given in the Upload-Length header.
+// The object name, which is required, and its content type are taken from the Upload-Metadata header. The request
+// can already include some data
+func postResumableUpload(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	bucket, err := getBucketAndCheckAccess(ctx, ctx.URLParams.ByName("bucket"), "id", goose.PermWrite)
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	if r.Header.Get("Upload-Defer-Length") != "" {
+		return ghttp.NewError(400, "the upload length can not be deferred")
+	}
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return ghttp.NewError(400, "invalid value for Upload-Length")
+	}
+	if bucket.Quota.MaxObjectSize > 0 && length > bucket.Quota.MaxObjectSize {
+		return ghttp.ProcessError(goose.ErrObjectTooLarge)
+	}
+	upload, err := resumableUploadFromMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		return err
+	}
+	upload.BucketID, upload.Length = bucket.ID, length
+	if ctx.User != nil {
+		upload.Owner = ctx.User.Subject
+	}
+	if err = resumables.Create(upload); err != nil {
+		return err
+	}
+	w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+upload.ID)
+
+	if r.ContentLength > 0 && r.Header.Get("Content-Type") == tusContentType {
+		if err = appendResumableUpload(w, r, ctx, bucket, upload); err != nil {
+			return err
+		}
+	} else if length == 0 {
+		if err = finishResumableUpload(w, ctx, bucket, upload); err != nil {
+			return err
+		}
+	}
+	setResumableUploadHeaders(w, upload)
+	w.WriteHeader(201)
+	return nil
+}
+
+// resumableUploadFromMetadata returns a resumable upload with the object name and metadata given in the value of
+// the Upload-Metadata header, as comma separated keys and base64 encoded values. The name is given with the name
+// or filename keys and the content type with the contentType or filetype keys, and the unknown keys are kept in
+// the custom metadata
+func resumableUploadFromMetadata(header string) (*goose.ResumableUpload, error) {
+	upload := &goose.ResumableUpload{}
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 || len(fields) > 2 {
+			continue
+		}
+		var value string
+		if len(fields) == 2 {
+			decoded, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, ghttp.NewError(400, "invalid value for Upload-Metadata")
+			}
+			value = string(decoded)
+		}
+		switch fields[0] {
+		case "name", "filename":
+			upload.Name = value
+		case "contentType", "filetype":
+			upload.ContentType = value
+		case "title":
+			upload.Metadata.Title = value
+		case "description":
+			upload.Metadata.Description = value
+		case "cacheControl":
+			upload.Metadata.CacheControl = value
+		default:
+			if upload.Metadata.Custom == nil {
+				upload.Metadata.Custom = make(map[string]interface{})
+			}
+			upload.Metadata.Custom[fields[0]] = value
+		}
+	}
+	if upload.Name == "" {
+		return nil, ghttp.NewError(400, "missing object name in Upload-Metadata")
+	}
+	upload.Name = ghttp.PrefixSlash(upload.Name)
+	return upload, nil
+}
+
+// findResumableUpload returns the resumable upload in the URL, which must belong to a bucket where the user can
+// upload objects, and have been created by the user
+func findResumableUpload(ctx *ghttp.Context) (*goose.Bucket, *goose.ResumableUpload, error) {
+	bucket, err := getBucketAndCheckAccess(ctx, ctx.URLParams.ByName("bucket"), "id", goose.PermWrite)
+	if err != nil {
+		return nil, nil, ghttp.ProcessError(err)
+	}
+	upload, err := resumables.Find(ctx.URLParams.ByName("upload"))
+	if err != nil {
+		return nil, nil, ghttp.ProcessError(err)
+	}
+	if upload.BucketID != bucket.ID {
+		return nil, nil, ghttp.NewError(404, "")
+	}
+	if ctx.User != nil && upload.Owner != ctx.User.Subject && !ctx.User.HasRole(goose.RoleAdmin) {
+		return nil, nil, ghttp.NewError(404, "")
+	}
+	return bucket, upload, nil
+}
+
+// setResumableUploadHeaders sets the headers with the state of a resumable upload. Once complete, the ID of the
+// object is sent in the X-Object-Id header
+func setResumableUploadHeaders(w http.ResponseWriter, upload *goose.ResumableUpload) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	if upload.ObjectID != "" {
+		w.Header().Set("X-Object-Id", upload.ObjectID)
+		return
+	}
+	w.Header().Set("Upload-Expires", upload.Updated.Add(uploadExpiry).UTC().Format(http.TimeFormat))
+}
+
+// headResumableUpload tells the number of bytes received for a resumable upload, to resume it from there
+func headResumableUpload(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	_, upload, err := findResumableUpload(ctx)
+	if err != nil {
+		return err
+	}
+	setResumableUploadHeaders(w, upload)
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(200)
+	return nil
+}
+
+// patchResumableUpload appends data to a resumable upload, at the offset given in the Upload-Offset header. Once
+// all the data is received, it is stored as the object
+func patchResumableUpload(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	if r.Header.Get("Content-Type") != tusContentType {
+		return ghttp.NewError(415, "the content type must be "+tusContentType)
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return ghttp.NewError(400, "invalid value for Upload-Offset")
+	}
+	unlock, err := resumables.Lock(ctx.URLParams.ByName("upload"))
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	defer unlock()
+
+	bucket, upload, err := findResumableUpload(ctx)
+	if err != nil {
+		return err
+	}
+	if upload.ObjectID != "" && offset == upload.Length {
+		// The object is already stored, the client missed the response to the last request
+		setResumableUploadHeaders(w, upload)
+		w.WriteHeader(204)
+		return nil
+	}
+	if offset != upload.Offset {
+		return ghttp.ProcessError(goose.ErrOffsetMismatch)
+	}
+	if err = appendResumableUpload(w, r, ctx, bucket, upload); err != nil {
+		return err
+	}
+	w.WriteHeader(204)
+	return nil
+}
+
+// appendResumableUpload appends the request body to a resumable upload, storing the object once complete, and
+// sets the response headers with its new state
+func appendResumableUpload(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context, bucket *goose.Bucket, upload *goose.ResumableUpload) error {
+	offset, err := resumables.Append(upload.ID, upload.Offset, r.Body)
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	upload.Offset, upload.Updated = offset, time.Now()
+	if upload.Complete() {
+		return finishResumableUpload(w, ctx, bucket, upload)
+	}
+	setResumableUploadHeaders(w, upload)
+	return nil
+}
+
+// finishResumableUpload stores the data of a complete resumable upload as the object. If it fails, the data is
+// kept, so the client can retry by sending no more data at the final offset
+func finishResumableUpload(w http.ResponseWriter, ctx *ghttp.Context, bucket *goose.Bucket, upload *goose.ResumableUpload) error {
+	data, err := resumables.Open(upload.ID)
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+
+	object, err := goose.PutObject(ctx.Storage.Objects(), bucket, data, upload.Length, upload.Name, upload.ContentType, &upload.Metadata)
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	if err = resumables.Finish(upload, object.ID.Hex()); err != nil {
+		goose.Log.Error(fmt.Sprintf("error removing the data of the completed upload %s: %v", upload.ID, err))
+	}
+	setResumableUploadHeaders(w, upload)
+	return nil
+}
+
+// deleteResumableUpload cancels a resumable upload, discarding its data (tus termination extension)
+func deleteResumableUpload(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	unlock, err := resumables.Lock(ctx.URLParams.ByName("upload"))
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	defer unlock()
+
+	_, upload, err := findResumableUpload(ctx)
+	if err != nil {
+		return err
+	}
+	if err = resumables.Remove(upload.ID); err != nil {
+		return ghttp.ProcessError(err)
+	}
+	w.WriteHeader(204)
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/syb-devs/goose"
+	ghttp "github.com/syb-devs/goose/http"
+)
+
+// ErrRoleInUse represents an HTTP 409 error, returned when deleting a role still given to some user
+var ErrRoleInUse = ghttp.NewError(409, "the role is given to some users")
+
+type reqRole struct {
+	Name        *string
+	Description *string
+}
+
+func roleFromRequest(r *http.Request) (*reqRole, error) {
+	role := &reqRole{}
+	if err := json.NewDecoder(r.Body).Decode(role); err != nil {
+		return nil, ghttp.NewError(400, "invalid role data")
+	}
+	return role, nil
+}
+
+func postRole(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	if !ctx.User.HasRole(goose.RoleAdmin) {
+		return ErrAdminRequired
+	}
+	req, err := roleFromRequest(r)
+	if err != nil {
+		return err
+	}
+	if req.Name == nil || *req.Name == "" {
+		return ghttp.NewError(400, "missing name")
+	}
+	if *req.Name == goose.RoleAdmin {
+		return ghttp.NewError(409, "the admin role is built in")
+	}
+	role := &goose.Role{Name: *req.Name}
+	if req.Description != nil {
+		role.Description = *req.Description
+	}
+	if err = ctx.Storage.Roles().Insert(role); err != nil {
+		return ghttp.ProcessError(err)
+	}
+	w.Header().Set("Location", "/roles/"+role.ID.Hex())
+	return ghttp.WriteJSON(w, 201, role)
+}
+
+// listRoles lists the stored roles, sorted by name
+func listRoles(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	if !ctx.User.HasRole(goose.RoleAdmin) {
+		return ErrAdminRequired
+	}
+	roles, err := ctx.Storage.Roles().Find()
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	return ghttp.WriteJSON(w, 200, roles)
+}
+
+func getRole(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	if !ctx.User.HasRole(goose.RoleAdmin) {
+		return ErrAdminRequired
+	}
+	role, err := ctx.Storage.Roles().FindId(ctx.URLParams.ByName("role"))
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	return ghttp.WriteJSON(w, 200, role)
+}
+
+// putRole updates the description of a role. The name can not be changed, as users and ACLs refer to it
+func putRole(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	if !ctx.User.HasRole(goose.RoleAdmin) {
+		return ErrAdminRequired
+	}
+	repo := ctx.Storage.Roles()
+	role, err := repo.FindId(ctx.URLParams.ByName("role"))
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	req, err := roleFromRequest(r)
+	if err != nil {
+		return err
+	}
+	if req.Name != nil && *req.Name != role.Name {
+		return ghttp.NewError(400, "the role name can not be changed")
+	}
+	if req.Description != nil {
+		role.Description = *req.Description
+	}
+	if err = repo.Update(role); err != nil {
+		return ghttp.ProcessError(err)
+	}
+	return ghttp.WriteJSON(w, 200, role)
+}
+
+// deleteRole deletes a role, unless it is given to some user
+func deleteRole(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	if !ctx.User.HasRole(goose.RoleAdmin) {
+		return ErrAdminRequired
+	}
+	repo := ctx.Storage.Roles()
+	role, err := repo.FindId(ctx.URLParams.ByName("role"))
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	users, err := ctx.Storage.Users().Find()
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	for _, user := range users {
+		if user.HasRole(role.Name) {
+			return ErrRoleInUse
+		}
+	}
+	return ghttp.ProcessError(repo.DeleteId(role.ID.Hex()))
+}
@@ -2,26 +2,75 @@ package main
 
 import (
 	"github.com/dimfeld/httptreemux"
+	"github.com/syb-devs/goose"
 	ghttp "github.com/syb-devs/goose/http"
 )
 
 func newRouter() *httptreemux.TreeMux {
 	rt := httptreemux.New()
-	ctx := ghttp.HandlerAdapterTreeMux
+	// Every route requires an authenticated user allowed the given scope, unless stated otherwise
+	ctx := func(scope string, f ghttp.HandlerFunc) httptreemux.HandlerFunc {
+		return ghttp.HandlerAdapterTreeMux(ghttp.Authenticated(ghttp.Scoped(scope, f)))
+	}
 
-	rt.POST("/buckets", ctx(postBucket))
-	rt.GET("/buckets/:bucket", ctx(getBucket))
-	rt.GET("/buckets/name/:bucket", ctx(getBucketByName))
-	rt.PUT("/buckets/:bucket", ctx(putBucket))
-	rt.DELETE("/buckets/:bucket", ctx(deleteBucket))
+	rt.GET("/buckets", ctx(goose.ScopeBucketRead, listBuckets))
+	rt.POST("/buckets", ctx(goose.ScopeBucketWrite, postBucket))
+	rt.GET("/buckets/:bucket", ctx(goose.ScopeBucketRead, getBucket))
+	rt.GET("/buckets/name/:bucket", ctx(goose.ScopeBucketRead, getBucketByName))
+	rt.PUT("/buckets/:bucket", ctx(goose.ScopeBucketWrite, putBucket))
+	rt.DELETE("/buckets/:bucket", ctx(goose.ScopeBucketWrite, deleteBucket))
+	rt.POST("/buckets/:bucket/stats", ctx(goose.ScopeBucketWrite, recomputeBucketStats))
+	rt.POST("/buckets/:bucket/policies", ctx(goose.ScopeObjectWrite, postUploadPolicy))
+	rt.GET("/buckets/:bucket/acl", ctx(goose.ScopeBucketWrite, getBucketACL))
+	rt.PUT("/buckets/:bucket/acl", ctx(goose.ScopeBucketWrite, putBucketACL))
 
-	rt.GET("/buckets/:bucket/objects", ctx(listObjects))
-	rt.GET("/buckets/:bucket/objects/list/:objects", ctx(listObjectsByIds))
-	rt.POST("/buckets/:bucket/objects", ctx(postObject))
-	rt.GET("/buckets/:bucket/objects/:object", ctx(getObject))
-	rt.DELETE("/buckets/:bucket/objects/:object", ctx(deleteObject))
+	rt.GET("/buckets/:bucket/objects", ctx(goose.ScopeObjectRead, listObjects))
+	rt.GET("/buckets/:bucket/objects/list/:objects", ctx(goose.ScopeObjectRead, listObjectsByIds))
+	// Objects can be uploaded without credentials using a signed upload policy
+	rt.POST("/buckets/:bucket/objects", ghttp.HandlerAdapterTreeMux(ghttp.Scoped(goose.ScopeObjectWrite, postObject)))
+	rt.GET("/buckets/:bucket/objects/:object", ctx(goose.ScopeObjectRead, getObject))
+	rt.GET("/buckets/:bucket/objects/:object/data", ctx(goose.ScopeObjectRead, getObjectData))
+	rt.POST("/buckets/:bucket/objects/:object/url", ctx(goose.ScopeObjectRead, signObjectURL))
+	rt.DELETE("/buckets/:bucket/objects/:object", ctx(goose.ScopeObjectDelete, deleteObject))
 
-	rt.PUT("/buckets/:bucket/objects/:object/metadata", ctx(putObjectMetadata))
+	rt.PUT("/buckets/:bucket/objects/:object/metadata", ctx(goose.ScopeObjectWrite, putObjectMetadata))
+	rt.POST("/buckets/:bucket/objects/:object/restore", ctx(goose.ScopeObjectWrite, restoreObjectVersion))
+
+	rt.POST("/buckets/:bucket/uploads", ctx(goose.ScopeObjectWrite, postMultipartUpload))
+	rt.GET("/buckets/:bucket/uploads/:upload", ctx(goose.ScopeObjectWrite, getMultipartUpload))
+	rt.PUT("/buckets/:bucket/uploads/:upload/parts/:part", ctx(goose.ScopeObjectWrite, putUploadPart))
+	rt.POST("/buckets/:bucket/uploads/:upload/complete", ctx(goose.ScopeObjectWrite, completeMultipartUpload))
+	rt.DELETE("/buckets/:bucket/uploads/:upload", ctx(goose.ScopeObjectWrite, abortMultipartUpload))
+
+	// Resumable uploads with the tus protocol. Its clients discover the server capabilities without credentials
+	rt.OPTIONS("/buckets/:bucket/tus", ghttp.HandlerAdapterTreeMux(optionsResumableUpload))
+	rt.POST("/buckets/:bucket/tus", ctx(goose.ScopeObjectWrite, tus(postResumableUpload)))
+	rt.HEAD("/buckets/:bucket/tus/:upload", ctx(goose.ScopeObjectWrite, tus(headResumableUpload)))
+	rt.PATCH("/buckets/:bucket/tus/:upload", ctx(goose.ScopeObjectWrite, tus(patchResumableUpload)))
+	rt.DELETE("/buckets/:bucket/tus/:upload", ctx(goose.ScopeObjectWrite, tus(deleteResumableUpload)))
+
+	rt.GET("/buckets/:bucket/versions", ctx(goose.ScopeObjectRead, listObjectVersions))
+	rt.DELETE("/buckets/:bucket/versions", ctx(goose.ScopeObjectDelete, pruneObjectVersions))
+
+	rt.GET("/jobs/:job", ctx(goose.ScopeBucketRead, getJob))
+
+	rt.GET("/users", ctx(goose.ScopeAdmin, listUsers))
+	rt.POST("/users", ctx(goose.ScopeAdmin, postUser))
+	rt.GET("/users/me", ctx("", getCurrentUser))
+	rt.GET("/users/:user", ctx(goose.ScopeAdmin, getUser))
+	rt.PUT("/users/:user", ctx(goose.ScopeAdmin, putUser))
+	rt.DELETE("/users/:user", ctx(goose.ScopeAdmin, deleteUser))
+
+	rt.GET("/roles", ctx(goose.ScopeAdmin, listRoles))
+	rt.POST("/roles", ctx(goose.ScopeAdmin, postRole))
+	rt.GET("/roles/:role", ctx(goose.ScopeAdmin, getRole))
+	rt.PUT("/roles/:role", ctx(goose.ScopeAdmin, putRole))
+	rt.DELETE("/roles/:role", ctx(goose.ScopeAdmin, deleteRole))
+
+	rt.GET("/apikeys", ctx(goose.ScopeAdmin, listAPIKeys))
+	rt.POST("/apikeys", ctx(goose.ScopeAdmin, postAPIKey))
+	rt.GET("/apikeys/:key", ctx(goose.ScopeAdmin, getAPIKey))
+	rt.DELETE("/apikeys/:key", ctx(goose.ScopeAdmin, deleteAPIKey))
 
 	return rt
 }
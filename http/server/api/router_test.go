@@ -0,0 +1,535 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/syb-devs/goose"
+	ghttp "github.com/syb-devs/goose/http"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const testJWTKey = "router-test-key"
+
+// testServer runs the API router on a new memory storage, with JWT authentication and temporary upload
+// directories. Call the returned function to shut it down
+func testServer(t *testing.T) (*httptest.Server, func()) {
+	goose.SetDefaultStorage(goose.NewMemoryStorage())
+	cfg, err := ghttp.NewJWTConfig("HS256", []byte(testJWTKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ghttp.SetJWTConfig(cfg)
+
+	dir, err := ioutil.TempDir("", "goose-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumables, err = goose.NewResumableStore(dir + "/tus"); err != nil {
+		t.Fatal(err)
+	}
+	if multiparts, err = goose.NewMultipartStore(dir + "/multipart"); err != nil {
+		t.Fatal(err)
+	}
+	uploadExpiry = time.Hour
+
+	srv := httptest.NewServer(newRouter())
+	return srv, func() {
+		srv.Close()
+		ghttp.SetJWTConfig(nil)
+		os.RemoveAll(dir)
+	}
+}
+
+// bearer returns the Authorization header with a token for the given subject and roles
+func bearer(t *testing.T, subject string, roles ...string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + token(t, jwt.MapClaims{"sub": subject, "roles": roles, "exp": time.Now().Add(time.Hour).Unix()})}}
+}
+
+// token returns a token with the given claims, signed with the test key
+func token(t *testing.T, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testJWTKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// call sends a request to the test server, checking the response status, and returns the response along with its
+// body
+func call(t *testing.T, srv *httptest.Server, method, path, body string, header http.Header, status int) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != status {
+		t.Fatalf("%s %s: expected status %d, got %d: %s", method, path, status, res.StatusCode, data)
+	}
+	return res, data
+}
+
+// decode unmarshals a JSON response body
+func decode(t *testing.T, data []byte, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("invalid JSON response %q: %v", data, err)
+	}
+}
+
+// withHeader returns a copy of a header with an additional value
+func withHeader(h http.Header, key, value string) http.Header {
+	c := http.Header{}
+	for k, v := range h {
+		c[k] = v
+	}
+	c.Set(key, value)
+	return c
+}
+
+// createBucket creates a bucket as the given user and returns its ID
+func createBucket(t *testing.T, srv *httptest.Server, auth http.Header, name string) string {
+	t.Helper()
+	_, data := call(t, srv, "POST", "/buckets", `{"Name":"`+name+`"}`, auth, 201)
+	b := &goose.Bucket{}
+	decode(t, data, b)
+	return b.ID.Hex()
+}
+
+// uploadObject uploads an object as the given user and returns it
+func uploadObject(t *testing.T, srv *httptest.Server, auth http.Header, bucketID, name, content string) *goose.Object {
+	t.Helper()
+	_, data := call(t, srv, "POST", "/buckets/"+bucketID+"/objects?name="+name, content, withHeader(auth, "Content-Type", "text/plain"), 201)
+	o := &goose.Object{}
+	decode(t, data, o)
+	return o
+}
+
+func TestRouterBuckets(t *testing.T) {
+	srv, done := testServer(t)
+	defer done()
+	admin, alice := bearer(t, "root", goose.RoleAdmin), bearer(t, "alice")
+
+	call(t, srv, "GET", "/buckets", "", nil, 401)
+	bucketID := createBucket(t, srv, alice, "photos")
+	call(t, srv, "POST", "/buckets", `{"Name":"photos"}`, alice, 409)
+	call(t, srv, "POST", "/buckets", `{}`, alice, 400)
+	call(t, srv, "POST", "/buckets", `{"Name":""}`, alice, 400)
+	call(t, srv, "POST", "/buckets", `{"Name":`, alice, 400)
+	call(t, srv, "GET", "/buckets/not-an-id", "", alice, 400)
+	call(t, srv, "GET", "/buckets/"+bucketID, "", alice, 200)
+	call(t, srv, "GET", "/buckets/name/photos", "", alice, 200)
+	call(t, srv, "GET", "/buckets/name/photos", "", bearer(t, "bob"), 403)
+
+	_, data := call(t, srv, "GET", "/buckets", "", alice, 200)
+	page := &bucketListPage{}
+	decode(t, data, page)
+	if len(page.Buckets) != 1 || page.Buckets[0].Name != "photos" {
+		t.Errorf("unexpected bucket list %s", data)
+	}
+
+	_, data = call(t, srv, "PUT", "/buckets/"+bucketID, `{"Versioning":true}`, alice, 200)
+	b := &goose.Bucket{}
+	decode(t, data, b)
+	if !b.Versioning {
+		t.Error("versioning not enabled")
+	}
+	call(t, srv, "GET", "/buckets/"+bucketID+"/acl", "", alice, 200)
+	call(t, srv, "POST", "/buckets/"+bucketID+"/stats", "", admin, 200)
+	call(t, srv, "DELETE", "/buckets/"+bucketID, "", bearer(t, "bob"), 403)
+	call(t, srv, "DELETE", "/buckets/"+bucketID, "", alice, 200)
+	call(t, srv, "GET", "/buckets/"+bucketID, "", alice, 404)
+}
+
+func TestRouterObjects(t *testing.T) {
+	srv, done := testServer(t)
+	defer done()
+	alice := bearer(t, "alice")
+
+	bucketID := createBucket(t, srv, alice, "docs")
+	o := uploadObject(t, srv, alice, bucketID, "/a/b.txt", "hello")
+	if o.Size != 5 || o.MD5 != "5d41402abc4b2a76b9719d911017c592" {
+		t.Errorf("unexpected object %+v", o)
+	}
+	other := uploadObject(t, srv, alice, bucketID, "/c.txt", "world")
+	objectPath := "/buckets/" + bucketID + "/objects/" + o.ID.Hex()
+
+	call(t, srv, "GET", objectPath, "", alice, 200)
+	call(t, srv, "GET", objectPath, "", bearer(t, "bob"), 403)
+	if _, data := call(t, srv, "GET", objectPath+"/data", "", alice, 200); string(data) != "hello" {
+		t.Errorf("expected hello, got %q", data)
+	}
+
+	_, data := call(t, srv, "GET", "/buckets/"+bucketID+"/objects?limit=1", "", alice, 200)
+	page := &objectListPage{}
+	decode(t, data, page)
+	if len(page.Objects) != 1 || page.Next == "" {
+		t.Errorf("unexpected object page %s", data)
+	}
+	_, data = call(t, srv, "GET", "/buckets/"+bucketID+"/objects/list/"+o.ID.Hex()+","+other.ID.Hex(), "", alice, 200)
+	var objects []*goose.Object
+	decode(t, data, &objects)
+	if len(objects) != 2 {
+		t.Errorf("expected 2 objects, got %s", data)
+	}
+
+	_, data = call(t, srv, "PUT", objectPath+"/metadata", `{"Title":"Greeting"}`, alice, 200)
+	updated := &goose.Object{}
+	decode(t, data, updated)
+	if updated.Metadata.Title != "Greeting" {
+		t.Errorf("title not updated: %s", data)
+	}
+
+	call(t, srv, "DELETE", objectPath, "", alice, 200)
+	call(t, srv, "GET", objectPath, "", alice, 404)
+}
+
+func TestRouterUploads(t *testing.T) {
+	srv, done := testServer(t)
+	defer done()
+	alice := bearer(t, "alice")
+
+	bucketID := createBucket(t, srv, alice, "multipart")
+	_, data := call(t, srv, "POST", "/buckets/"+bucketID+"/uploads", `{"Name":"big.bin","ContentType":"application/x-test"}`, alice, 201)
+	upload := &goose.MultipartUpload{}
+	decode(t, data, upload)
+	uploadPath := "/buckets/" + bucketID + "/uploads/" + upload.ID
+
+	call(t, srv, "GET", uploadPath, "", bearer(t, "bob", goose.RoleAdmin), 200)
+	call(t, srv, "PUT", uploadPath+"/parts/2", "world", alice, 200)
+	call(t, srv, "PUT", uploadPath+"/parts/1", "hello ", alice, 200)
+	call(t, srv, "PUT", uploadPath+"/parts/0", "x", alice, 400)
+
+	_, data = call(t, srv, "GET", uploadPath, "", alice, 200)
+	status := &multipartUploadStatus{}
+	decode(t, data, status)
+	if len(status.Parts) != 2 {
+		t.Errorf("expected 2 parts, got %s", data)
+	}
+
+	_, data = call(t, srv, "POST", uploadPath+"/complete", "", alice, 201)
+	o := &goose.Object{}
+	decode(t, data, o)
+	if _, data = call(t, srv, "GET", "/buckets/"+bucketID+"/objects/"+o.ID.Hex()+"/data", "", alice, 200); string(data) != "hello world" {
+		t.Errorf("expected hello world, got %q", data)
+	}
+	call(t, srv, "GET", uploadPath, "", alice, 404)
+
+	_, data = call(t, srv, "POST", "/buckets/"+bucketID+"/uploads", `{"Name":"aborted.bin"}`, alice, 201)
+	decode(t, data, upload)
+	call(t, srv, "DELETE", "/buckets/"+bucketID+"/uploads/"+upload.ID, "", alice, 204)
+}
+
+func TestRouterTus(t *testing.T) {
+	srv, done := testServer(t)
+	defer done()
+	alice := bearer(t, "alice")
+
+	bucketID := createBucket(t, srv, alice, "resumable")
+	res, _ := call(t, srv, "OPTIONS", "/buckets/"+bucketID+"/tus", "", nil, 204)
+	if res.Header.Get("Tus-Version") != tusVersion {
+		t.Errorf("unexpected Tus-Version %q", res.Header.Get("Tus-Version"))
+	}
+
+	h := withHeader(alice, "Upload-Length", "11")
+	h.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("tus.txt")))
+	call(t, srv, "POST", "/buckets/"+bucketID+"/tus", "", h, 412)
+	h.Set("Tus-Resumable", tusVersion)
+	res, _ = call(t, srv, "POST", "/buckets/"+bucketID+"/tus", "", h, 201)
+	location := strings.TrimPrefix(res.Header.Get("Location"), srv.URL)
+
+	p := withHeader(alice, "Tus-Resumable", tusVersion)
+	p.Set("Content-Type", tusContentType)
+	p.Set("Upload-Offset", "0")
+	call(t, srv, "PATCH", location, "hello ", p, 204)
+	call(t, srv, "PATCH", location, "world", p, 409)
+	res, _ = call(t, srv, "HEAD", location, "", p, 200)
+	if res.Header.Get("Upload-Offset") != "6" {
+		t.Errorf("expected offset 6, got %q", res.Header.Get("Upload-Offset"))
+	}
+	p.Set("Upload-Offset", "6")
+	res, _ = call(t, srv, "PATCH", location, "world", p, 204)
+	objectID := res.Header.Get("X-Object-Id")
+	if _, data := call(t, srv, "GET", "/buckets/"+bucketID+"/objects/"+objectID+"/data", "", alice, 200); string(data) != "hello world" {
+		t.Errorf("expected hello world, got %q", data)
+	}
+	call(t, srv, "DELETE", location, "", p, 204)
+	call(t, srv, "HEAD", location, "", p, 404)
+}
+
+func TestRouterVersions(t *testing.T) {
+	srv, done := testServer(t)
+	defer done()
+	alice := bearer(t, "alice")
+
+	bucketID := createBucket(t, srv, alice, "versioned")
+	call(t, srv, "PUT", "/buckets/"+bucketID, `{"Versioning":true}`, alice, 200)
+	first := uploadObject(t, srv, alice, bucketID, "/v.txt", "one")
+	uploadObject(t, srv, alice, bucketID, "/v.txt", "two")
+
+	var versions []*goose.Object
+	_, data := call(t, srv, "GET", "/buckets/"+bucketID+"/versions?name=/v.txt", "", alice, 200)
+	decode(t, data, &versions)
+	if len(versions) != 2 {
+		t.Fatalf("expected 2 versions, got %s", data)
+	}
+
+	_, data = call(t, srv, "POST", "/buckets/"+bucketID+"/objects/"+first.ID.Hex()+"/restore", "", alice, 201)
+	restored := &goose.Object{}
+	decode(t, data, restored)
+	if restored.Size != 3 || restored.ID == first.ID {
+		t.Errorf("unexpected restored object %s", data)
+	}
+
+	_, data = call(t, srv, "DELETE", "/buckets/"+bucketID+"/versions?name=/v.txt&keep=1", "", alice, 200)
+	decode(t, data, &versions)
+	if len(versions) != 2 {
+		t.Errorf("expected 2 pruned versions, got %s", data)
+	}
+	_, data = call(t, srv, "GET", "/buckets/"+bucketID+"/versions?name=/v.txt", "", alice, 200)
+	decode(t, data, &versions)
+	if len(versions) != 1 || versions[0].ID != restored.ID {
+		t.Errorf("expected the restored version to be kept, got %s", data)
+	}
+
+	// Restored versions count against the bucket quota
+	call(t, srv, "PUT", "/buckets/"+bucketID, `{"Quota":{"maxObjects":1}}`, alice, 200)
+	call(t, srv, "POST", "/buckets/"+bucketID+"/objects/"+restored.ID.Hex()+"/restore", "", alice, 507)
+}
+
+func TestRouterJobs(t *testing.T) {
+	srv, done := testServer(t)
+	defer done()
+	alice := bearer(t, "alice")
+
+	bucketID := createBucket(t, srv, alice, "purged")
+	for _, name := range []string{"/a", "/b", "/c"} {
+		uploadObject(t, srv, alice, bucketID, name, "x")
+	}
+	call(t, srv, "DELETE", "/buckets/"+bucketID, "", alice, 409)
+	res, _ := call(t, srv, "DELETE", "/buckets/"+bucketID+"?force=true", "", alice, 202)
+	location := res.Header.Get("Location")
+
+	j := &job{}
+	for i := 0; i < 100 && j.Status != jobDone; i++ {
+		_, data := call(t, srv, "GET", location, "", alice, 200)
+		decode(t, data, j)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if j.Status != jobDone || j.Done != 3 || j.BucketID.Hex() != bucketID {
+		t.Errorf("expected the job to be done with 3 objects, got %+v", j)
+	}
+	call(t, srv, "GET", location, "", bearer(t, "bob"), 403)
+	call(t, srv, "GET", location, "", bearer(t, "root", goose.RoleAdmin), 200)
+	call(t, srv, "GET", "/jobs/not-an-id", "", alice, 400)
+	call(t, srv, "GET", "/jobs/"+bson.NewObjectId().Hex(), "", alice, 404)
+}
+
+func TestRouterUsers(t *testing.T) {
+	srv, done := testServer(t)
+	defer done()
+	admin, carol := bearer(t, "root", goose.RoleAdmin), bearer(t, "carol")
+
+	call(t, srv, "GET", "/users", "", carol, 403)
+	_, data := call(t, srv, "POST", "/users", `{"Subject":"carol","Groups":["marketing"]}`, admin, 201)
+	u := &goose.User{}
+	decode(t, data, u)
+	call(t, srv, "POST", "/users", `{"Subject":"carol"}`, admin, 409)
+
+	_, data = call(t, srv, "GET", "/users/me", "", carol, 200)
+	me := &goose.User{}
+	decode(t, data, me)
+	if me.Subject != "carol" || len(me.Groups) != 1 {
+		t.Errorf("unexpected current user %s", data)
+	}
+
+	var users []*goose.User
+	_, data = call(t, srv, "GET", "/users", "", admin, 200)
+	decode(t, data, &users)
+	if len(users) != 1 {
+		t.Errorf("expected 1 user, got %s", data)
+	}
+	call(t, srv, "GET", "/users/"+u.ID.Hex(), "", admin, 200)
+	call(t, srv, "PUT", "/users/"+u.ID.Hex(), `{"Disabled":true}`, admin, 200)
+	call(t, srv, "GET", "/users/me", "", carol, 401)
+	call(t, srv, "DELETE", "/users/"+u.ID.Hex(), "", admin, 200)
+	call(t, srv, "GET", "/users/"+u.ID.Hex(), "", admin, 404)
+}
+
+func TestRouterRoles(t *testing.T) {
+	srv, done := testServer(t)
+	defer done()
+	admin := bearer(t, "root", goose.RoleAdmin)
+
+	call(t, srv, "GET", "/roles", "", bearer(t, "carol"), 403)
+	_, data := call(t, srv, "POST", "/roles", `{"Name":"editor","Description":"Edits"}`, admin, 201)
+	role := &goose.Role{}
+	decode(t, data, role)
+	call(t, srv, "POST", "/roles", `{"Name":"editor"}`, admin, 409)
+
+	var roles []*goose.Role
+	_, data = call(t, srv, "GET", "/roles", "", admin, 200)
+	decode(t, data, &roles)
+	if len(roles) != 1 || roles[0].Name != "editor" {
+		t.Errorf("unexpected roles %s", data)
+	}
+	_, data = call(t, srv, "PUT", "/roles/"+role.ID.Hex(), `{"Description":"Edits everything"}`, admin, 200)
+	decode(t, data, role)
+	if role.Description != "Edits everything" {
+		t.Errorf("description not updated: %s", data)
+	}
+	call(t, srv, "GET", "/roles/"+role.ID.Hex(), "", admin, 200)
+	call(t, srv, "DELETE", "/roles/"+role.ID.Hex(), "", admin, 200)
+	call(t, srv, "GET", "/roles/"+role.ID.Hex(), "", admin, 404)
+}
+
+// racyStorage is a storage where the buckets never seem to exist before being inserted, as when another request
+// creates the same bucket meanwhile
+type racyStorage struct {
+	goose.Storage
+}
+
+type racyBuckets struct {
+	goose.BucketStore
+}
+
+func (s racyStorage) Buckets() goose.BucketStore { return racyBuckets{s.Storage.Buckets()} }
+func (s racyStorage) Copy() goose.Storage        { return racyStorage{s.Storage.Copy()} }
+func (b racyBuckets) Exists(name string) bool    { return false }
+
+func TestRouterBucketRace(t *testing.T) {
+	srv, done := testServer(t)
+	defer done()
+	goose.SetDefaultStorage(racyStorage{goose.DefaultStorage()})
+	alice := bearer(t, "alice")
+
+	createBucket(t, srv, alice, "photos")
+	call(t, srv, "POST", "/buckets", `{"Name":"photos"}`, alice, 409)
+}
+
+func TestRouterAPIKeys(t *testing.T) {
+	srv, done := testServer(t)
+	defer done()
+	admin := bearer(t, "root", goose.RoleAdmin)
+
+	bucketID := createBucket(t, srv, bearer(t, "svc"), "keyed")
+	call(t, srv, "POST", "/apikeys", `{"Scopes":["bucket:read"]}`, bearer(t, "carol"), 403)
+	call(t, srv, "POST", "/apikeys", `{"Scopes":["bogus"]}`, admin, 400)
+	_, data := call(t, srv, "POST", "/apikeys", `{"Name":"reader","Owner":"svc","Scopes":["bucket:read","object:read"]}`, admin, 201)
+	created := &newAPIKey{}
+	decode(t, data, created)
+	key := http.Header{"X-Api-Key": {created.Key}}
+
+	call(t, srv, "GET", "/buckets/"+bucketID+"/objects", "", key, 200)
+	call(t, srv, "POST", "/buckets/"+bucketID+"/objects?name=x.txt", "x", key, 403)
+
+	var keys []*goose.APIKey
+	_, data = call(t, srv, "GET", "/apikeys", "", admin, 200)
+	decode(t, data, &keys)
+	if len(keys) != 1 || keys[0].Name != "reader" {
+		t.Errorf("unexpected API keys %s", data)
+	}
+	call(t, srv, "GET", "/apikeys/"+created.ID.Hex(), "", admin, 200)
+	call(t, srv, "DELETE", "/apikeys/"+created.ID.Hex(), "", admin, 200)
+	call(t, srv, "GET", "/buckets/"+bucketID+"/objects", "", key, 401)
+}
+
+func TestRouterAPIKeyGrants(t *testing.T) {
+	srv, done := testServer(t)
+	defer done()
+	admin := bearer(t, "root", goose.RoleAdmin)
+	keyedID := createBucket(t, srv, admin, "keyed")
+	otherID := createBucket(t, srv, admin, "other")
+	call(t, srv, "POST", "/roles", `{"Name":"auditor"}`, admin, 201)
+	call(t, srv, "POST", "/users", `{"Subject":"limited","Buckets":["keyed"]}`, admin, 201)
+	limited := bearer(t, "limited", goose.RoleAdmin)
+
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"Scopes":["admin"],"Roles":["ghost"]}`, 400},
+		{`{"Scopes":["admin"],"Roles":["auditor"]}`, 403},
+		{`{"Scopes":["admin"],"Roles":["admin"],"Owner":"root"}`, 403},
+		{`{"Scopes":["admin"],"Roles":["admin"],"Buckets":["other"]}`, 403},
+		{`{"Scopes":["admin"],"Roles":["admin"],"Buckets":["` + otherID + `"]}`, 403},
+		{`{"Scopes":["admin"],"Roles":["admin"],"Buckets":["` + keyedID + `"]}`, 201},
+	}
+	for _, test := range tests {
+		call(t, srv, "POST", "/apikeys", test.body, limited, test.status)
+	}
+
+	// A key of a restricted user inherits the restriction
+	_, data := call(t, srv, "POST", "/apikeys", `{"Scopes":["bucket:read"],"Roles":["admin"]}`, limited, 201)
+	created := &newAPIKey{}
+	decode(t, data, created)
+	if len(created.Buckets) != 1 || created.Buckets[0] != "keyed" || created.Owner != "limited" {
+		t.Errorf("expected a key of limited restricted to keyed, got %+v", created.APIKey)
+	}
+	key := http.Header{"X-Api-Key": {created.Key}}
+	call(t, srv, "GET", "/buckets/"+keyedID, "", key, 200)
+	call(t, srv, "GET", "/buckets/"+otherID, "", key, 403)
+
+	call(t, srv, "POST", "/apikeys", `{"Scopes":["admin"],"Roles":["admin","auditor"],"Owner":"svc"}`, bearer(t, "root", goose.RoleAdmin, "auditor"), 201)
+}
+
+func TestRouterTokens(t *testing.T) {
+	srv, done := testServer(t)
+	defer done()
+	header := func(token string) http.Header { return http.Header{"Authorization": {"Bearer " + token}} }
+
+	valid := token(t, jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()})
+	expired := token(t, jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(-time.Hour).Unix()})
+	forever := token(t, jwt.MapClaims{"sub": "alice"})
+	call(t, srv, "GET", "/buckets", "", header(valid), 200)
+	call(t, srv, "GET", "/buckets", "", header(expired), 401)
+	call(t, srv, "GET", "/buckets", "", header(forever), 401)
+	// Tokens in the query string are ignored unless allowed
+	call(t, srv, "GET", "/buckets?access_token="+valid, "", nil, 401)
+
+	cfg, err := ghttp.NewJWTConfig("HS256", []byte(testJWTKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.AllowNoExpiry, cfg.AllowQueryToken = true, true
+	ghttp.SetJWTConfig(cfg)
+	call(t, srv, "GET", "/buckets", "", header(forever), 200)
+	call(t, srv, "GET", "/buckets", "", header(expired), 401)
+	call(t, srv, "GET", "/buckets?access_token="+valid, "", nil, 200)
+}
+
+func TestRouterAuthConfig(t *testing.T) {
+	srv, done := testServer(t)
+	defer done()
+	alice := bearer(t, "alice")
+
+	// Without a JWT key the tokens can not be validated, so only anonymous access is left
+	ghttp.SetJWTConfig(nil)
+	call(t, srv, "GET", "/buckets", "", nil, 401)
+	call(t, srv, "GET", "/buckets", "", alice, 401)
+
+	ghttp.SetAuthDisabled(true)
+	defer ghttp.SetAuthDisabled(false)
+	call(t, srv, "GET", "/buckets", "", nil, 200)
+	call(t, srv, "GET", "/users", "", nil, 200)
+}
@@ -5,17 +5,49 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"time"
 
-	"github.com/syb-devs/dockerlink"
 	"github.com/syb-devs/goose"
+	ghttp "github.com/syb-devs/goose/http"
 )
 
 func main() {
-	goose.NewDBConn(goose.DBOptions{
-		Database:     envDefault("DBNAME", "goose"),
-		URL:          getMongoURI(),
-		SetAsDefault: true,
-	})
+	storage, err := goose.StorageFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	goose.SetDefaultStorage(storage)
+
+	jwtConfig, err := ghttp.JWTConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	authDisabled, err := ghttp.AuthDisabledFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if authDisabled {
+		log.Printf("WARNING: authentication is disabled with AUTH_DISABLED, every request has access to everything")
+	} else if jwtConfig == nil {
+		log.Fatal("no JWT key, set JWT_KEY or JWT_KEY_FILE, or AUTH_DISABLED=true to run without authentication")
+	}
+	ghttp.SetJWTConfig(jwtConfig)
+	ghttp.SetAuthDisabled(authDisabled)
+	ghttp.SetSigningKey(ghttp.SigningKeyFromEnv())
+	filesURL = os.Getenv("FILES_URL")
+
+	uploadPath := envDefault("UPLOAD_PATH", filepath.Join(os.TempDir(), "goose-uploads"))
+	if resumables, err = goose.NewResumableStore(filepath.Join(uploadPath, "tus")); err != nil {
+		log.Fatal(err)
+	}
+	if multiparts, err = goose.NewMultipartStore(filepath.Join(uploadPath, "multipart")); err != nil {
+		log.Fatal(err)
+	}
+	if uploadExpiry, err = time.ParseDuration(envDefault("UPLOAD_EXPIRY", "24h")); err != nil {
+		log.Fatalf("invalid UPLOAD_EXPIRY: %v", err)
+	}
+	go expireUploads()
 
 	addr := fmt.Sprintf(":%s", envDefault("PORT", "8080"))
 
@@ -23,6 +55,24 @@ func main() {
 	log.Fatal(http.ListenAndServe(addr, newRouter()))
 }
 
+// expireUploads periodically removes the uploads that were abandoned before completing them
+func expireUploads() {
+	for range time.Tick(time.Hour) {
+		n, err := resumables.Expire(uploadExpiry)
+		if err != nil {
+			goose.Log.Error(fmt.Sprintf("error expiring the resumable uploads: %v", err))
+		} else if n > 0 {
+			goose.Log.Info(fmt.Sprintf("removed %d expired resumable uploads", n))
+		}
+		n, err = multiparts.Expire(uploadExpiry)
+		if err != nil {
+			goose.Log.Error(fmt.Sprintf("error expiring the multipart uploads: %v", err))
+		} else if n > 0 {
+			goose.Log.Info(fmt.Sprintf("removed %d expired multipart uploads", n))
+		}
+	}
+}
+
 func envDefault(key, defval string) string {
 	val := os.Getenv(key)
 	if val != "" {
@@ -30,13 +80,3 @@ func envDefault(key, defval string) string {
 	}
 	return defval
 }
-
-func getMongoURI() string {
-	if uri := os.Getenv("MONGO_URL"); uri != "" {
-		return uri
-	}
-	if link, err := dockerlink.GetLink(envDefault("DOCKER_MONGO_NAME", "mongodb"), 27017, "tcp"); err == nil {
-		return fmt.Sprintf("%s:%d", link.Address, link.Port)
-	}
-	panic("mongodb connection not found, use MONGO_URL env var or a docker link with mongodb name")
-}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"mime"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/syb-devs/goose"
+	ghttp "github.com/syb-devs/goose/http"
+)
+
+const (
+	defaultSignedURLExpiry = time.Hour
+	maxSignedURLExpiry     = 7 * 24 * time.Hour
+)
+
+// filesURL is the base URL of the file server, used to build the signed URLs
+var filesURL string
+
+type reqSignedURL struct {
+	// Expires is the number of seconds the URL is valid for
+	Expires     int
+	IP          string
+	Disposition string
+}
+
+// signedURL is the response body of the signed URL endpoint
+type signedURL struct {
+	URL     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
+// signObjectURL issues a signed, expiring URL to download an object version from the file server, regardless of
+// the bucket visibility
+func signObjectURL(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	bucket, err := getBucketAndCheckAccess(ctx, ctx.URLParams.ByName("bucket"), "id", goose.PermRead)
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	object, err := ctx.Storage.Objects().FindId(ctx.URLParams.ByName("object"))
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	if object.Metadata.BucketID != bucket.ID {
+		return ghttp.NewError(404, "")
+	}
+
+	req := &reqSignedURL{}
+	if err = json.NewDecoder(r.Body).Decode(req); err != nil {
+		return ghttp.NewError(400, "invalid signed URL data")
+	}
+	expiry := defaultSignedURLExpiry
+	if req.Expires != 0 {
+		expiry = time.Duration(req.Expires) * time.Second
+	}
+	if expiry <= 0 || expiry > maxSignedURLExpiry {
+		return ghttp.NewError(400, "invalid value for Expires, use up to 7 days")
+	}
+	if req.IP != "" && net.ParseIP(req.IP) == nil {
+		return ghttp.NewError(400, "invalid value for IP")
+	}
+	if req.Disposition != "" {
+		if _, _, err = mime.ParseMediaType(req.Disposition); err != nil {
+			return ghttp.NewError(400, "invalid value for Disposition")
+		}
+	}
+
+	sig := &ghttp.URLSignature{
+		Bucket:      bucket.Name,
+		Object:      object.Name,
+		VersionID:   object.ID.Hex(),
+		Expires:     time.Now().Add(expiry).Truncate(time.Second),
+		IP:          req.IP,
+		Disposition: req.Disposition,
+	}
+	path, err := sig.Sign()
+	if err != nil {
+		return err
+	}
+	return ghttp.WriteJSON(w, 201, signedURL{URL: filesURL + path, Expires: sig.Expires})
+}
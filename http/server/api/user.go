@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/syb-devs/goose"
+	ghttp "github.com/syb-devs/goose/http"
+)
+
+type reqUser struct {
+	Subject  *string
+	Name     *string
+	Email    *string
+	Roles    []string
+	Groups   []string
+	Buckets  []string
+	Disabled *bool
+}
+
+func (u *reqUser) Apply(user *goose.User) {
+	if u.Subject != nil {
+		user.Subject = *u.Subject
+	}
+	if u.Name != nil {
+		user.Name = *u.Name
+	}
+	if u.Email != nil {
+		user.Email = *u.Email
+	}
+	if u.Roles != nil {
+		user.Roles = u.Roles
+	}
+	if u.Groups != nil {
+		user.Groups = u.Groups
+	}
+	if u.Buckets != nil {
+		user.Buckets = u.Buckets
+	}
+	if u.Disabled != nil {
+		user.Disabled = *u.Disabled
+	}
+}
+
+// checkRoles checks that every role is stored, or is the built-in admin role
+func checkRoles(repo goose.RoleStore, roles []string) error {
+	for _, role := range roles {
+		if role == goose.RoleAdmin {
+			continue
+		}
+		if _, err := repo.FindName(role); err != nil {
+			if err == goose.ErrNotFound {
+				return ghttp.NewError(400, "unknown role "+role)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func userFromRequest(r *http.Request) (*reqUser, error) {
+	user := &reqUser{}
+	if err := json.NewDecoder(r.Body).Decode(user); err != nil {
+		return nil, ghttp.NewError(400, "invalid user data")
+	}
+	return user, nil
+}
+
+func postUser(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	if !ctx.User.HasRole(goose.RoleAdmin) {
+		return ErrAdminRequired
+	}
+	req, err := userFromRequest(r)
+	if err != nil {
+		return err
+	}
+	if req.Subject == nil || *req.Subject == "" {
+		return ghttp.NewError(400, "missing subject")
+	}
+	if err = checkRoles(ctx.Storage.Roles(), req.Roles); err != nil {
+		return ghttp.ProcessError(err)
+	}
+	user := &goose.User{}
+	req.Apply(user)
+	if err = ctx.Storage.Users().Insert(user); err != nil {
+		return ghttp.ProcessError(err)
+	}
+	w.Header().Set("Location", "/users/"+user.ID.Hex())
+	return ghttp.WriteJSON(w, 201, user)
+}
+
+// listUsers lists the stored users, sorted by subject
+func listUsers(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	if !ctx.User.HasRole(goose.RoleAdmin) {
+		return ErrAdminRequired
+	}
+	users, err := ctx.Storage.Users().Find()
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	return ghttp.WriteJSON(w, 200, users)
+}
+
+// getCurrentUser returns the user making the request, with the roles, groups and buckets used for access control
+func getCurrentUser(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	return ghttp.WriteJSON(w, 200, ctx.User)
+}
+
+func getUser(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	if !ctx.User.HasRole(goose.RoleAdmin) {
+		return ErrAdminRequired
+	}
+	user, err := ctx.Storage.Users().FindId(ctx.URLParams.ByName("user"))
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	return ghttp.WriteJSON(w, 200, user)
+}
+
+func putUser(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	if !ctx.User.HasRole(goose.RoleAdmin) {
+		return ErrAdminRequired
+	}
+	repo := ctx.Storage.Users()
+	user, err := repo.FindId(ctx.URLParams.ByName("user"))
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	req, err := userFromRequest(r)
+	if err != nil {
+		return err
+	}
+	if req.Subject != nil && *req.Subject == "" {
+		return ghttp.NewError(400, "missing subject")
+	}
+	if err = checkRoles(ctx.Storage.Roles(), req.Roles); err != nil {
+		return ghttp.ProcessError(err)
+	}
+	req.Apply(user)
+	if err = repo.Update(user); err != nil {
+		return ghttp.ProcessError(err)
+	}
+	return ghttp.WriteJSON(w, 200, user)
+}
+
+func deleteUser(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	if !ctx.User.HasRole(goose.RoleAdmin) {
+		return ErrAdminRequired
+	}
+	return ghttp.ProcessError(ctx.Storage.Users().DeleteId(ctx.URLParams.ByName("user")))
+}
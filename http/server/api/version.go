@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/syb-devs/goose"
+	ghttp "github.com/syb-devs/goose/http"
+)
+
+// ErrNoVersionName is returned by the version handlers when no object name is given
+var ErrNoVersionName = ghttp.NewError(400, "missing object name")
+
+func listObjectVersions(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	bucket, err := getBucketAndCheckAccess(ctx, ctx.URLParams.ByName("bucket"), "id", goose.PermRead)
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		return ErrNoVersionName
+	}
+	versions, err := ctx.Storage.Objects().FindVersions(bucket.ID, ghttp.PrefixSlash(name))
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	defer versions.Close()
+	return ghttp.WriteJSON(w, 200, versions.Objects())
+}
+
+// restoreObjectVersion makes a copy of a previous version of an object, which becomes its newest version
+func restoreObjectVersion(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	bucket, err := getBucketAndCheckAccess(ctx, ctx.URLParams.ByName("bucket"), "id", goose.PermWrite)
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	repo := ctx.Storage.Objects()
+	version, err := repo.OpenId(ctx.URLParams.ByName("object"))
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	defer version.Close()
+	if version.Metadata.BucketID != bucket.ID {
+		return ghttp.NewError(404, "")
+	}
+
+	meta := *version.Metadata
+	object, err := goose.PutObject(repo, bucket, version.File(), version.Size, version.Name, version.ContentType, &meta)
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	return ghttp.WriteJSON(w, 201, object)
+}
+
+// pruneObjectVersions deletes the old versions of an object, keeping the newest ones (just one by default)
+func pruneObjectVersions(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	bucket, err := getBucketAndCheckAccess(ctx, ctx.URLParams.ByName("bucket"), "id", goose.PermDelete)
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	query := r.URL.Query()
+	name := query.Get("name")
+	if name == "" {
+		return ErrNoVersionName
+	}
+	keep := 1
+	if k := query.Get("keep"); k != "" {
+		keep, err = strconv.Atoi(k)
+		if err != nil || keep < 0 {
+			return ghttp.NewError(400, "invalid value for keep")
+		}
+	}
+
+	repo := ctx.Storage.Objects()
+	versions, err := repo.FindVersions(bucket.ID, ghttp.PrefixSlash(name))
+	if err != nil {
+		return ghttp.ProcessError(err)
+	}
+	defer versions.Close()
+
+	pruned := []*goose.Object{}
+	for i, version := range versions.Objects() {
+		if i < keep {
+			continue
+		}
+		if err = repo.DeleteId(version.ID.Hex()); err != nil {
+			return ghttp.ProcessError(err)
+		}
+		pruned = append(pruned, version)
+	}
+	return ghttp.WriteJSON(w, 200, pruned)
+}
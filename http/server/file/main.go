@@ -2,14 +2,12 @@ package main
 
 import (
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
 
-	"github.com/syb-devs/dockerlink"
 	"github.com/syb-devs/goose"
 	ghttp "github.com/syb-devs/goose/http"
 )
@@ -19,11 +17,29 @@ import (
 var ErrNoBucketURL = ghttp.NewError(404, "invalid bucket in URL")
 
 func main() {
-	goose.NewDBConn(goose.DBOptions{
-		Database:     envDefault("DBNAME", "goose"),
-		URL:          getMongoURI(),
-		SetAsDefault: true,
-	})
+	storage, err := goose.StorageFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	goose.SetDefaultStorage(storage)
+
+	jwtConfig, err := ghttp.JWTConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	authDisabled, err := ghttp.AuthDisabledFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if authDisabled {
+		log.Printf("WARNING: authentication is disabled with AUTH_DISABLED, every request has access to everything")
+	} else if jwtConfig == nil {
+		log.Fatal("no JWT key, set JWT_KEY or JWT_KEY_FILE, or AUTH_DISABLED=true to run without authentication")
+	}
+	ghttp.SetJWTConfig(jwtConfig)
+	ghttp.SetAuthDisabled(authDisabled)
+	ghttp.SetSigningKey(ghttp.SigningKeyFromEnv())
+	ghttp.SetClientIPHeader(ghttp.ClientIPHeaderFromEnv())
 
 	addr := fmt.Sprintf(":%s", envDefault("PORT", "80"))
 	log.Printf("Goose file server listening on %s", addr)
@@ -39,16 +55,6 @@ func envDefault(key, defval string) string {
 	return defval
 }
 
-func getMongoURI() string {
-	if uri := os.Getenv("MONGO_URL"); uri != "" {
-		return uri
-	}
-	if link, err := dockerlink.GetLink(envDefault("DOCKER_MONGO_NAME", "mongodb"), 27017, "tcp"); err == nil {
-		return fmt.Sprintf("%s:%d", link.Address, link.Port)
-	}
-	panic("mongodb connection not found, use MONGO_URL env var or a docker link with mongodb name")
-}
-
 func serveObject(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
 	bucketName, fileName, err := getBucketObjectNames(r)
 	goose.Log.Debug(fmt.Sprintf("serving object [%s] from bucket [%s]", fileName, bucketName))
@@ -56,36 +62,76 @@ func serveObject(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) err
 		return ghttp.ProcessError(err)
 	}
 
-	fileName, err = url.QueryUnescape(fileName)
+	fileName, err = url.PathUnescape(fileName)
 	if err != nil {
 		return ghttp.ProcessError(err)
 	}
 	goose.Log.Debug(fmt.Sprintf("unescaped fileName: [%s]", fileName))
 
-	br := goose.NewBucketRepo(ctx.DB)
+	br := ctx.Storage.Buckets()
 	bucket, err := br.FindName(bucketName)
 
 	if err != nil {
 		return ghttp.ProcessError(err)
 	}
+	if ghttp.IsSigned(r) {
+		// Signed URLs give access to the object regardless of the bucket visibility
+		sig, err := ghttp.VerifySignature(r, bucketName, fileName)
+		if err != nil {
+			return ghttp.NewError(403, err.Error())
+		}
+		if sig.Disposition != "" {
+			w.Header().Set("Content-Disposition", sig.Disposition)
+		}
+	} else if !ctx.User.CanDownload(bucket) {
+		if ctx.User == nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			return ghttp.ErrUnauthorized
+		}
+		return ghttp.ErrForbidden
+	}
 
-	repo := goose.NewObjectRepo(ctx.DB)
-	obj, err := repo.OpenFromBucket(fileName, bucket.ID)
+	obj, err := openObject(ctx, r, bucket, fileName)
 	if err != nil {
 		return ghttp.ProcessError(err)
 	}
 
-	defer obj.GridFile().Close()
-	_, err = io.Copy(w, obj.GridFile())
-	return err
+	defer obj.Close()
+	cacheControl := bucket.CacheControl
+	if cacheControl == "" && bucket.Visibility != "" && bucket.Visibility != goose.VisibilityPublic {
+		// Keep shared caches from serving the object to other users
+		cacheControl = "private"
+	}
+	ghttp.ServeObject(w, r, obj, cacheControl)
+	return nil
+}
+
+// openObject opens the newest version of the named object in the bucket, or the one given with the versionId
+// query parameter
+func openObject(ctx *ghttp.Context, r *http.Request, bucket *goose.Bucket, name string) (*goose.Object, error) {
+	repo := ctx.Storage.Objects()
+	versionID := r.URL.Query().Get("versionId")
+	if versionID == "" {
+		return repo.OpenFromBucket(name, bucket.ID)
+	}
+	obj, err := repo.OpenId(versionID)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Metadata.BucketID != bucket.ID || obj.Name != name {
+		obj.Close()
+		return nil, goose.ErrNotFound
+	}
+	return obj, nil
 }
 
 func getBucketObjectNames(r *http.Request) (bucket, object string, err error) {
+	path := r.URL.EscapedPath()
 	subdomain := ghttp.GetSubdomain(r)
 	if subdomain != "storage" && subdomain != "" {
-		return subdomain, r.URL.RequestURI(), nil
+		return subdomain, path, nil
 	}
-	urlParts := strings.SplitN(r.URL.RequestURI()[1:], "/", 2)
+	urlParts := strings.SplitN(path[1:], "/", 2)
 	if len(urlParts) < 2 {
 		return "", "", ErrNoBucketURL
 	}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/syb-devs/goose"
+	ghttp "github.com/syb-devs/goose/http"
+)
+
+func TestServeSignedObject(t *testing.T) {
+	storage := goose.NewMemoryStorage()
+	goose.SetDefaultStorage(storage)
+	ghttp.SetSigningKey([]byte("file-test-key"))
+	defer ghttp.SetSigningKey(nil)
+
+	bucket := &goose.Bucket{Name: "docs", Visibility: goose.VisibilityPrivate}
+	if err := storage.Buckets().Insert(bucket); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(ghttp.HandlerAdapter(serveObject)))
+	defer srv.Close()
+	get := func(path string) (*http.Response, []byte) {
+		req, err := http.NewRequest("GET", srv.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Host = "storage.goose.test"
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, _ := ioutil.ReadAll(res.Body)
+		return res, body
+	}
+
+	// Plus signs are literal in paths, so they must not be read as spaces
+	for _, name := range []string{"/a+b.txt", "/a b.txt", "/100%.txt"} {
+		if _, err := storage.Objects().Create(strings.NewReader(name), name, "text/plain", &goose.ObjectMetadata{BucketID: bucket.ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"/a+b.txt", "/a b.txt", "/100%.txt"} {
+		sig := &ghttp.URLSignature{Bucket: "docs", Object: name, Expires: time.Now().Add(time.Hour)}
+		path, err := sig.Sign()
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, body := get(path)
+		if res.StatusCode != 200 || string(body) != name {
+			t.Errorf("%s: expected 200 with the object data, got %d %q", name, res.StatusCode, body)
+		}
+
+		res, _ = get(path[:strings.Index(path, "?")])
+		if res.StatusCode != 401 {
+			t.Errorf("%s: expected 401 without the signature, got %d", name, res.StatusCode)
+		}
+	}
+}
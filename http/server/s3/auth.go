@@ -0,0 +1,481 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/syb-devs/goose"
+	ghttp "github.com/syb-devs/goose/http"
+)
+
+const (
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat  = "20060102T150405Z"
+	// maxClockSkew is how far the time of a signed request can be from the server time
+	maxClockSkew = 15 * time.Minute
+	// maxPresignExpiry is the longest validity of a presigned URL
+	maxPresignExpiry = 7 * 24 * time.Hour
+
+	unsignedPayload          = "UNSIGNED-PAYLOAD"
+	streamingPayload         = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+)
+
+// emptySHA256 is the hex encoded SHA-256 of an empty string
+var emptySHA256 = hex.EncodeToString(sha256.New().Sum(nil))
+
+// credentials maps the access key IDs to their secret access keys
+var credentials map[string]string
+
+// authDisabled makes every request be made by a user with access to everything, set with AUTH_DISABLED=true
+var authDisabled bool
+
+// credentialsFromEnv returns the credentials set with the S3_CREDENTIALS env var, a comma separated list of
+// access key ID and secret access key pairs, joined by a colon. It returns nil if the var is not set
+func credentialsFromEnv() (map[string]string, error) {
+	env := os.Getenv("S3_CREDENTIALS")
+	if env == "" {
+		return nil, nil
+	}
+	creds := make(map[string]string)
+	for _, pair := range strings.Split(env, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.New("invalid S3_CREDENTIALS, use a comma separated list of key:secret pairs")
+		}
+		creds[parts[0]] = parts[1]
+	}
+	return creds, nil
+}
+
+// authenticate returns the user making the request, given by the access key ID of its AWS Signature Version 4,
+// sent in the Authorization header or in the query of a presigned URL. The access key ID is the subject of the
+// user, whose stored record, if any, gives its roles, groups and buckets. It is nil for anonymous requests
+func authenticate(r *http.Request, storage goose.Storage) (*goose.User, error) {
+	if authDisabled {
+		return &goose.User{Roles: []string{goose.RoleAdmin}}, nil
+	}
+	var sig *signature
+	var err error
+	header := r.Header.Get("Authorization")
+	switch {
+	case strings.HasPrefix(header, sigV4Algorithm+" "):
+		sig, err = parseAuthorization(r, header)
+	case hasQuery(r, "X-Amz-Algorithm"):
+		sig, err = parsePresigned(r)
+	case header != "":
+		return nil, ErrUnsupportedSignature
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	secret, ok := credentials[sig.accessKey]
+	if !ok {
+		return nil, ErrInvalidAccessKeyID
+	}
+	sig.key = signingKey(secret, sig.date, sig.region, sig.service)
+	if !hmac.Equal([]byte(sig.sign(sig.stringToSign(r))), []byte(sig.signature)) {
+		return nil, ErrSignatureDoesNotMatch
+	}
+	if err = wrapBody(r, sig); err != nil {
+		return nil, err
+	}
+	return ghttp.LoadUserRecord(storage, &goose.User{Subject: sig.accessKey})
+}
+
+// signature holds the parameters of the AWS signature of a request
+type signature struct {
+	accessKey     string
+	date          string
+	region        string
+	service       string
+	signedHeaders []string
+	signature     string
+	time          time.Time
+	payloadHash   string
+	key           []byte
+}
+
+// scope returns the credential scope of the signature
+func (s *signature) scope() string {
+	return strings.Join([]string{s.date, s.region, s.service, "aws4_request"}, "/")
+}
+
+// parseCredential parses the access key ID and scope of the signature, given as
+// <key>/<date>/<region>/<service>/aws4_request
+func (s *signature) parseCredential(credential string) error {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[4] != "aws4_request" || parts[3] != "s3" {
+		return ErrAuthorizationMalformed
+	}
+	s.accessKey, s.date, s.region, s.service = parts[0], parts[1], parts[2], parts[3]
+	return nil
+}
+
+// parseTime parses the time of the request, which must be within the signature scope date
+func (s *signature) parseTime(amzDate string) error {
+	t, err := time.Parse(amzDateFormat, amzDate)
+	if err != nil || t.Format("20060102") != s.date {
+		return ErrAuthorizationMalformed
+	}
+	s.time = t
+	return nil
+}
+
+// parseAuthorization parses the signature sent in the Authorization header:
+// AWS4-HMAC-SHA256 Credential=<credential>, SignedHeaders=<headers>, Signature=<signature>
+func parseAuthorization(r *http.Request, header string) (*signature, error) {
+	s := &signature{}
+	fields := map[string]string{}
+	for _, field := range strings.Split(strings.TrimPrefix(header, sigV4Algorithm+" "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(kv) == 2 {
+			fields[kv[0]] = kv[1]
+		}
+	}
+	if fields["SignedHeaders"] == "" || fields["Signature"] == "" {
+		return nil, ErrAuthorizationMalformed
+	}
+	if err := s.parseCredential(fields["Credential"]); err != nil {
+		return nil, err
+	}
+	amzDate := r.Header.Get("X-Amz-Date")
+	if amzDate == "" {
+		if t, err := http.ParseTime(r.Header.Get("Date")); err == nil {
+			amzDate = t.UTC().Format(amzDateFormat)
+		}
+	}
+	if err := s.parseTime(amzDate); err != nil {
+		return nil, err
+	}
+	if skew := time.Since(s.time); skew > maxClockSkew || skew < -maxClockSkew {
+		return nil, ErrRequestTimeTooSkewed
+	}
+	s.signedHeaders = strings.Split(fields["SignedHeaders"], ";")
+	s.signature = fields["Signature"]
+	s.payloadHash = r.Header.Get("X-Amz-Content-Sha256")
+	if s.payloadHash == "" {
+		return nil, ErrMissingContentSHA256
+	}
+	return s, nil
+}
+
+// parsePresigned parses the signature sent in the query of a presigned URL
+func parsePresigned(r *http.Request) (*signature, error) {
+	query := r.URL.Query()
+	if query.Get("X-Amz-Algorithm") != sigV4Algorithm {
+		return nil, ErrUnsupportedSignature
+	}
+	s := &signature{}
+	if err := s.parseCredential(query.Get("X-Amz-Credential")); err != nil {
+		return nil, err
+	}
+	if err := s.parseTime(query.Get("X-Amz-Date")); err != nil {
+		return nil, err
+	}
+	expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil || expires < 0 || time.Duration(expires)*time.Second > maxPresignExpiry {
+		return nil, newError(400, "AuthorizationQueryParametersError", "X-Amz-Expires must be between 0 and 604800 seconds")
+	}
+	if s.time.Sub(time.Now()) > maxClockSkew {
+		return nil, ErrRequestTimeTooSkewed
+	}
+	if time.Now().After(s.time.Add(time.Duration(expires) * time.Second)) {
+		return nil, ErrExpiredRequest
+	}
+	s.signedHeaders = strings.Split(query.Get("X-Amz-SignedHeaders"), ";")
+	s.signature = query.Get("X-Amz-Signature")
+	s.payloadHash = unsignedPayload
+	return s, nil
+}
+
+// signingKey derives the key of the signatures made in a date, region and service from the secret access key
+func signingKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// sign returns the hex encoded signature of a string
+func (s *signature) sign(stringToSign string) string {
+	return hex.EncodeToString(hmacSHA256(s.key, stringToSign))
+}
+
+// stringToSign returns the string signed by the client for the request
+func (s *signature) stringToSign(r *http.Request) string {
+	return strings.Join([]string{
+		sigV4Algorithm,
+		s.time.Format(amzDateFormat),
+		s.scope(),
+		hexSHA256(s.canonicalRequest(r)),
+	}, "\n")
+}
+
+// canonicalRequest returns the canonical form of the request, as defined by the AWS Signature Version 4
+func (s *signature) canonicalRequest(r *http.Request) string {
+	return strings.Join([]string{
+		r.Method,
+		uriEncode(r.URL.Path, false),
+		canonicalQuery(r),
+		s.canonicalHeaders(r),
+		strings.Join(s.signedHeaders, ";"),
+		s.payloadHash,
+	}, "\n")
+}
+
+// canonicalQuery returns the query parameters sorted by name and value, except for the signature itself
+func canonicalQuery(r *http.Request) string {
+	params := []string{}
+	for name, values := range r.URL.Query() {
+		if name == "X-Amz-Signature" {
+			continue
+		}
+		for _, value := range values {
+			params = append(params, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// canonicalHeaders returns the signed headers, one per line, with their names lowercased and their values trimmed
+func (s *signature) canonicalHeaders(r *http.Request) string {
+	var buf bytes.Buffer
+	for _, name := range s.signedHeaders {
+		var value string
+		switch name {
+		case "host":
+			value = r.Host
+		case "content-length":
+			value = strconv.FormatInt(r.ContentLength, 10)
+		default:
+			values := []string{}
+			for _, v := range r.Header[http.CanonicalHeaderKey(name)] {
+				values = append(values, strings.Join(strings.Fields(v), " "))
+			}
+			value = strings.Join(values, ",")
+		}
+		buf.WriteString(name + ":" + value + "\n")
+	}
+	return buf.String()
+}
+
+// uriEncode percent-encodes every byte of s but the unreserved characters, and the slashes unless encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			buf.WriteByte(c)
+			continue
+		}
+		buf.WriteByte('%')
+		buf.WriteByte(hexDigits[c>>4])
+		buf.WriteByte(hexDigits[c&15])
+	}
+	return buf.String()
+}
+
+// wrapBody wraps the request body to check it against the signed payload hash and the Content-MD5 header,
+// decoding it if sent with the aws-chunked encoding
+func wrapBody(r *http.Request, s *signature) error {
+	switch s.payloadHash {
+	case unsignedPayload:
+	case streamingPayload, streamingUnsignedTrailer:
+		size, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+		if err != nil {
+			return newError(411, "MissingContentLength", "You must provide the X-Amz-Decoded-Content-Length HTTP header")
+		}
+		cr := &chunkedReader{r: bufio.NewReader(r.Body)}
+		if s.payloadHash == streamingPayload {
+			cr.sig, cr.prevSignature = s, s.signature
+		}
+		r.Body = readCloser{cr, r.Body}
+		r.ContentLength = size
+	default:
+		sum, err := hex.DecodeString(s.payloadHash)
+		if err != nil || len(sum) != sha256.Size {
+			return ErrContentSHA256Mismatch
+		}
+		r.Body = readCloser{&checksumReader{r: r.Body, h: sha256.New(), sum: sum, err: ErrContentSHA256Mismatch}, r.Body}
+	}
+	if header := r.Header.Get("Content-MD5"); header != "" {
+		sum, err := base64.StdEncoding.DecodeString(header)
+		if err != nil || len(sum) != md5.Size {
+			return ErrInvalidDigest
+		}
+		r.Body = readCloser{&checksumReader{r: r.Body, h: md5.New(), sum: sum, err: ErrBadDigest}, r.Body}
+	}
+	return nil
+}
+
+// readCloser reads from a wrapper of the request body, and closes the body itself
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// checksumReader fails with the given error at the end of the data if its checksum does not match,
+// so the storage driver aborts the write
+type checksumReader struct {
+	r   io.Reader
+	h   hash.Hash
+	sum []byte
+	err error
+}
+
+func (cr *checksumReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.h.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(cr.h.Sum(nil), cr.sum) {
+		return n, cr.err
+	}
+	return n, err
+}
+
+// chunkedReader decodes a body sent with the aws-chunked encoding, where each chunk is preceded by its hex encoded
+// size and, if the payload is signed, its signature, which is checked at the end of the chunk. The trailing
+// headers, if any, are skipped
+type chunkedReader struct {
+	r             *bufio.Reader
+	sig           *signature
+	prevSignature string
+	chunkSig      string
+	chunkHash     hash.Hash
+	remaining     int64
+	done          bool
+	err           error
+}
+
+func (cr *chunkedReader) Read(p []byte) (int, error) {
+	for cr.err == nil && !cr.done && cr.remaining == 0 {
+		cr.err = cr.nextChunk()
+	}
+	if cr.err != nil {
+		return 0, cr.err
+	}
+	if cr.done {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > cr.remaining {
+		p = p[:cr.remaining]
+	}
+	n, err := cr.r.Read(p)
+	cr.chunkHash.Write(p[:n])
+	cr.remaining -= int64(n)
+	if err == io.EOF {
+		err = ErrIncompleteBody
+	}
+	if err == nil && cr.remaining == 0 {
+		err = cr.endChunk()
+	}
+	cr.err = err
+	return n, err
+}
+
+// nextChunk reads the header of the next chunk, with its size and signature
+func (cr *chunkedReader) nextChunk() error {
+	line, err := cr.readLine()
+	if err != nil {
+		return err
+	}
+	fields := strings.SplitN(line, ";", 2)
+	size, err := strconv.ParseInt(fields[0], 16, 64)
+	if err != nil || size < 0 {
+		return ErrIncompleteBody
+	}
+	cr.chunkSig = ""
+	if len(fields) == 2 {
+		cr.chunkSig = strings.TrimPrefix(fields[1], "chunk-signature=")
+	}
+	cr.chunkHash = sha256.New()
+	cr.remaining = size
+	if size > 0 {
+		return nil
+	}
+	if err = cr.checkChunk(); err != nil {
+		return err
+	}
+	// Skip the trailing headers, up to the final empty line
+	for {
+		line, err = cr.readLine()
+		if err == io.EOF || (err == nil && line == "") {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	cr.done = true
+	return nil
+}
+
+// endChunk reads the line break after the data of a chunk, and checks its signature
+func (cr *chunkedReader) endChunk() error {
+	if line, err := cr.readLine(); err != nil || line != "" {
+		return ErrIncompleteBody
+	}
+	return cr.checkChunk()
+}
+
+// checkChunk checks the signature of the chunk just read, which is chained to the signature of the previous one
+func (cr *chunkedReader) checkChunk() error {
+	if cr.sig == nil {
+		return nil
+	}
+	expected := cr.sig.sign(strings.Join([]string{
+		sigV4Algorithm + "-PAYLOAD",
+		cr.sig.time.Format(amzDateFormat),
+		cr.sig.scope(),
+		cr.prevSignature,
+		emptySHA256,
+		hex.EncodeToString(cr.chunkHash.Sum(nil)),
+	}, "\n"))
+	if !hmac.Equal([]byte(expected), []byte(cr.chunkSig)) {
+		return ErrSignatureDoesNotMatch
+	}
+	cr.prevSignature = cr.chunkSig
+	return nil
+}
+
+// readLine reads a line terminated by CRLF, without the line break
+func (cr *chunkedReader) readLine() (string, error) {
+	line, err := cr.r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = ErrIncompleteBody
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/syb-devs/goose"
+	ghttp "github.com/syb-devs/goose/http"
+)
+
+const (
+	// maxKeys is the maximum number of keys returned by a listing
+	maxKeys = 1000
+	// timeFormat is the format of the times in the S3 documents
+	timeFormat = "2006-01-02T15:04:05.000Z"
+)
+
+// bucketNameRegexp matches the bucket names allowed by S3
+var bucketNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// unsupportedBucketQueries are the query parameters of the bucket operations not implemented by the gateway
+var unsupportedBucketQueries = []string{
+	"accelerate", "acl", "analytics", "cors", "encryption", "intelligent-tiering", "inventory", "lifecycle",
+	"logging", "metrics", "notification", "object-lock", "ownershipControls", "policy", "policyStatus",
+	"publicAccessBlock", "replication", "requestPayment", "tagging", "uploads", "versions", "website",
+}
+
+type owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type bucketEntry struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+type listAllMyBucketsResult struct {
+	XMLName xml.Name      `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListAllMyBucketsResult"`
+	Owner   owner         `xml:"Owner"`
+	Buckets []bucketEntry `xml:"Buckets>Bucket"`
+}
+
+// findBucket finds a bucket by name, checking that the user is allowed the given operation in it
+func findBucket(ctx *ghttp.Context, perm goose.Permission) (*goose.Bucket, error) {
+	bucket, err := lookupBucket(ctx, ctx.URLParams.ByName("bucket"))
+	if err != nil {
+		return nil, err
+	}
+	if !ctx.User.Can(bucket, perm) {
+		return nil, ErrAccessDenied
+	}
+	return bucket, nil
+}
+
+// listBuckets lists the buckets the user can read (ListBuckets)
+func listBuckets(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	if ctx.User == nil {
+		return ErrAccessDenied
+	}
+	blist, err := ctx.Storage.Buckets().Find(goose.BucketListOptions{Filter: ctx.User.CanReadBucket})
+	if err != nil {
+		return err
+	}
+	res := listAllMyBucketsResult{
+		Owner:   owner{ID: ctx.User.Subject, DisplayName: ctx.User.Subject},
+		Buckets: []bucketEntry{},
+	}
+	for _, b := range blist.Buckets() {
+		res.Buckets = append(res.Buckets, bucketEntry{Name: b.Name, CreationDate: formatTime(b.ID.Time())})
+	}
+	return writeXML(w, 200, res)
+}
+
+// putBucket creates a bucket (CreateBucket), or sets its versioning (PutBucketVersioning)
+func putBucket(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	if hasQuery(r, "versioning") {
+		return putBucketVersioning(w, r, ctx)
+	}
+	if hasQuery(r, unsupportedBucketQueries...) {
+		return ErrNotImplemented
+	}
+	if ctx.User == nil {
+		return ErrAccessDenied
+	}
+	name := ctx.URLParams.ByName("bucket")
+	if !bucketNameRegexp.MatchString(name) {
+		return ErrInvalidBucketName
+	}
+	// The location constraint in the body is ignored, every bucket is in the region of the gateway
+	io.Copy(ioutil.Discard, r.Body)
+
+	repo := ctx.Storage.Buckets()
+	if existing, err := repo.FindName(name); err == nil {
+		if existing.ACL.Owner != "" && existing.ACL.Owner == ctx.User.Subject {
+			return ErrBucketAlreadyOwned
+		}
+		return ErrBucketAlreadyExists
+	}
+	bucket := &goose.Bucket{Name: name, ACL: goose.ACL{Owner: ctx.User.Subject}}
+	err := repo.Insert(bucket)
+	if err == goose.ErrDuplicateKey {
+		return ErrBucketAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Location", "/"+name)
+	return nil
+}
+
+// headBucket tells whether the bucket exists and the user can read it (HeadBucket)
+func headBucket(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	_, err := findBucket(ctx, goose.PermRead)
+	return err
+}
+
+// deleteBucket deletes an empty bucket (DeleteBucket)
+func deleteBucket(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	if hasQuery(r, unsupportedBucketQueries...) {
+		return ErrNotImplemented
+	}
+	bucket, err := findBucket(ctx, goose.PermAdmin)
+	if err != nil {
+		return err
+	}
+	page, err := ctx.Storage.Objects().FindByBucket(bucket.ID, goose.ListOptions{Limit: 1})
+	if err != nil {
+		return err
+	}
+	page.Close()
+	if len(page.Objects()) > 0 {
+		return ErrBucketNotEmpty
+	}
+	if err = ctx.Storage.Buckets().DeleteId(bucket.ID.Hex()); err != nil {
+		return err
+	}
+	w.WriteHeader(204)
+	return nil
+}
+
+// getBucket lists the objects of a bucket (ListObjects and ListObjectsV2), or returns its location
+// (GetBucketLocation) or versioning (GetBucketVersioning)
+func getBucket(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	switch {
+	case hasQuery(r, "location"):
+		return getBucketLocation(w, r, ctx)
+	case hasQuery(r, "versioning"):
+		return getBucketVersioning(w, r, ctx)
+	case hasQuery(r, unsupportedBucketQueries...):
+		return ErrNotImplemented
+	}
+	return listObjects(w, r, ctx)
+}
+
+type locationConstraint struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LocationConstraint"`
+	Location string   `xml:",chardata"`
+}
+
+// getBucketLocation returns the region of the bucket (GetBucketLocation)
+func getBucketLocation(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	if _, err := findBucket(ctx, goose.PermRead); err != nil {
+		return err
+	}
+	location := region
+	if location == "us-east-1" {
+		// S3 reports the buckets in the default region with an empty location
+		location = ""
+	}
+	return writeXML(w, 200, locationConstraint{Location: location})
+}
+
+type versioningConfiguration struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ VersioningConfiguration"`
+	Status  string   `xml:"Status,omitempty"`
+}
+
+func getBucketVersioning(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	bucket, err := findBucket(ctx, goose.PermRead)
+	if err != nil {
+		return err
+	}
+	res := versioningConfiguration{}
+	if bucket.Versioning {
+		res.Status = "Enabled"
+	}
+	return writeXML(w, 200, res)
+}
+
+func putBucketVersioning(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	bucket, err := findBucket(ctx, goose.PermAdmin)
+	if err != nil {
+		return err
+	}
+	config := struct{ Status string }{}
+	if err = readXML(r, &config); err != nil {
+		return err
+	}
+	switch config.Status {
+	case "Enabled":
+		bucket.Versioning = true
+	case "Suspended":
+		bucket.Versioning = false
+	default:
+		return ErrMalformedXML
+	}
+	return ctx.Storage.Buckets().Update(bucket)
+}
+
+type objectEntry struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+	Owner        *owner `xml:"Owner,omitempty"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type listBucketResult struct {
+	XMLName               xml.Name       `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Marker                *string        `xml:"Marker,omitempty"`
+	NextMarker            string         `xml:"NextMarker,omitempty"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	KeyCount              *int           `xml:"KeyCount,omitempty"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	EncodingType          string         `xml:"EncodingType,omitempty"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	Contents              []objectEntry  `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+// listEntry is an object or a common prefix in a listing
+type listEntry struct {
+	key    string
+	object *goose.Object
+}
+
+// listObjects lists the objects of a bucket by key, with the newest version of each (ListObjects and
+// ListObjectsV2). Goose lists objects by upload date, so every object under the prefix is read to sort them
+func listObjects(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	bucket, err := findBucket(ctx, goose.PermRead)
+	if err != nil {
+		return err
+	}
+	query := r.URL.Query()
+	v2 := query.Get("list-type") == "2"
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	max := maxKeys
+	if mk := query.Get("max-keys"); mk != "" {
+		if max, err = strconv.Atoi(mk); err != nil || max < 0 {
+			return newError(400, "InvalidArgument", "max-keys must be a non negative integer")
+		}
+		if max > maxKeys {
+			max = maxKeys
+		}
+	}
+	encode := func(s string) string { return s }
+	res := listBucketResult{Name: bucket.Name, MaxKeys: max, Delimiter: delimiter}
+	if query.Get("encoding-type") == "url" {
+		res.EncodingType = "url"
+		encode = func(s string) string { return uriEncode(s, false) }
+	}
+	res.Prefix = encode(prefix)
+
+	after := query.Get("marker")
+	if v2 {
+		after = query.Get("start-after")
+		res.StartAfter = encode(after)
+		if token := query.Get("continuation-token"); token != "" {
+			key, err := base64.RawURLEncoding.DecodeString(token)
+			if err != nil {
+				return newError(400, "InvalidArgument", "The continuation token provided is incorrect")
+			}
+			after, res.ContinuationToken = string(key), token
+		}
+	} else {
+		marker := encode(after)
+		res.Marker = &marker
+	}
+
+	olist, err := ctx.Storage.Objects().FindByBucket(bucket.ID, goose.ListOptions{Prefix: "/" + prefix})
+	if err != nil {
+		return err
+	}
+	defer olist.Close()
+
+	entries := listEntries(olist.Objects(), prefix, delimiter, after)
+	if len(entries) > max {
+		entries = entries[:max]
+		res.IsTruncated = true
+		// With max-keys=0 nothing is listed, so there is no key to continue from
+		if max > 0 {
+			last := entries[len(entries)-1].key
+			if v2 {
+				res.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(last))
+			} else {
+				res.NextMarker = encode(last)
+			}
+		}
+	}
+	for _, e := range entries {
+		if e.object == nil {
+			res.CommonPrefixes = append(res.CommonPrefixes, commonPrefix{Prefix: encode(e.key)})
+			continue
+		}
+		entry := objectEntry{
+			Key:          encode(e.key),
+			LastModified: formatTime(e.object.UploadDate),
+			ETag:         strconv.Quote(e.object.MD5),
+			Size:         e.object.Size,
+			StorageClass: "STANDARD",
+		}
+		if !v2 || query.Get("fetch-owner") == "true" {
+			entry.Owner = &owner{}
+		}
+		res.Contents = append(res.Contents, entry)
+	}
+	if v2 {
+		count := len(entries)
+		res.KeyCount = &count
+	}
+	return writeXML(w, 200, res)
+}
+
+// listEntries returns the newest version of the given objects, and the common prefixes grouping them by the
+// delimiter, sorted by key and starting after the given one. The objects must be listed newest first
+func listEntries(objects []*goose.Object, prefix, delimiter, after string) []listEntry {
+	entries := []listEntry{}
+	seen := make(map[string]bool)
+	seenPrefixes := make(map[string]bool)
+	for _, o := range objects {
+		key := objectKey(o.Name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				key = key[:len(prefix)+i+len(delimiter)]
+				if seenPrefixes[key] {
+					continue
+				}
+				seenPrefixes[key] = true
+				if key > after {
+					entries = append(entries, listEntry{key: key})
+				}
+				continue
+			}
+		}
+		if key > after {
+			entries = append(entries, listEntry{key: key, object: o})
+		}
+	}
+	sort.Sort(entriesByKey(entries))
+	return entries
+}
+
+type entriesByKey []listEntry
+
+func (e entriesByKey) Len() int           { return len(e) }
+func (e entriesByKey) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e entriesByKey) Less(i, j int) bool { return e[i].key < e[j].key }
+
+// postBucket deletes objects in batch (DeleteObjects)
+func postBucket(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	if !hasQuery(r, "delete") {
+		return ErrNotImplemented
+	}
+	return deleteObjects(w, r, ctx)
+}
+
+// objectKey returns the S3 key of an object, its name without the leading slash
+func objectKey(name string) string {
+	return strings.TrimPrefix(name, "/")
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
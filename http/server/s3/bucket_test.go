@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/syb-devs/goose"
+)
+
+// testServer runs the gateway on a new memory storage, with a bucket holding the given objects, and
+// authentication disabled
+func testServer(t *testing.T, bucketName string, objects ...string) (*httptest.Server, func()) {
+	storage := goose.NewMemoryStorage()
+	goose.SetDefaultStorage(storage)
+	bucket := &goose.Bucket{Name: bucketName}
+	if err := storage.Buckets().Insert(bucket); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range objects {
+		if _, err := storage.Objects().Create(strings.NewReader(name), "/"+name, "text/plain", &goose.ObjectMetadata{BucketID: bucket.ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	authDisabled = true
+	srv := httptest.NewServer(newRouter())
+	return srv, func() {
+		srv.Close()
+		authDisabled = false
+	}
+}
+
+// get sends a GET request to the test server, checking the response status, and returns the response body
+func get(t *testing.T, srv *httptest.Server, path string, status int) []byte {
+	t.Helper()
+	res, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != status {
+		t.Fatalf("GET %s: expected %d, got %d: %s", path, status, res.StatusCode, body)
+	}
+	return body
+}
+
+func TestListObjectsMaxKeys(t *testing.T) {
+	srv, done := testServer(t, "list", "a.txt", "b.txt", "c.txt")
+	defer done()
+
+	tests := []struct {
+		query     string
+		keys      int
+		truncated bool
+		next      bool
+	}{
+		{"?max-keys=0", 0, true, false},
+		{"?list-type=2&max-keys=0", 0, true, false},
+		{"?max-keys=2", 2, true, true},
+		{"?list-type=2&max-keys=2", 2, true, true},
+		{"?max-keys=3", 3, false, false},
+	}
+	for _, test := range tests {
+		res := &listBucketResult{}
+		if err := xml.Unmarshal(get(t, srv, "/list"+test.query, 200), res); err != nil {
+			t.Fatal(err)
+		}
+		next := res.NextMarker != "" || res.NextContinuationToken != ""
+		if len(res.Contents) != test.keys || res.IsTruncated != test.truncated || next != test.next {
+			t.Errorf("%s: expected %d keys, truncated %v and next %v, got %+v", test.query, test.keys, test.truncated, test.next, res)
+		}
+	}
+
+	// An empty listing is not truncated
+	empty := &listBucketResult{}
+	if err := xml.Unmarshal(get(t, srv, "/list?max-keys=0&prefix=none", 200), empty); err != nil {
+		t.Fatal(err)
+	}
+	if empty.IsTruncated {
+		t.Error("expected an empty listing not to be truncated")
+	}
+	get(t, srv, "/list?max-keys=-1", 400)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+
+	"github.com/syb-devs/goose"
+	ghttp "github.com/syb-devs/goose/http"
+)
+
+// Error is an S3 error, sent as an XML document with its code and message
+type Error struct {
+	Status  int    `xml:"-"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// Error returns the S3 error code and message as a string
+func (e *Error) Error() string {
+	return fmt.Sprintf("S3 %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+func newError(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// The S3 errors returned by the gateway
+var (
+	ErrAccessDenied           = newError(403, "AccessDenied", "Access Denied")
+	ErrAnonymousOverride      = newError(400, "InvalidRequest", "Request specific response headers cannot be used for anonymous GET requests.")
+	ErrBadDigest              = newError(400, "BadDigest", "The Content-MD5 you specified did not match what was received")
+	ErrBucketAlreadyExists    = newError(409, "BucketAlreadyExists", "The requested bucket name is not available")
+	ErrBucketAlreadyOwned     = newError(409, "BucketAlreadyOwnedByYou", "The bucket already exists and is owned by you")
+	ErrBucketNotEmpty         = newError(409, "BucketNotEmpty", "The bucket you tried to delete is not empty")
+	ErrContentSHA256Mismatch  = newError(400, "XAmzContentSHA256Mismatch", "The provided x-amz-content-sha256 header does not match what was computed")
+	ErrEntityTooLarge         = newError(400, "EntityTooLarge", "Your proposed upload exceeds the maximum allowed object size")
+	ErrExpiredRequest         = newError(403, "AccessDenied", "Request has expired")
+	ErrIncompleteBody         = newError(400, "IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header")
+	ErrInternalError          = newError(500, "InternalError", "We encountered an internal error. Please try again.")
+	ErrInvalidAccessKeyID     = newError(403, "InvalidAccessKeyId", "The AWS access key ID you provided does not exist in our records")
+	ErrInvalidArgument        = newError(400, "InvalidArgument", "Invalid argument")
+	ErrInvalidBucketName      = newError(400, "InvalidBucketName", "The specified bucket is not valid")
+	ErrInvalidDigest          = newError(400, "InvalidDigest", "The Content-MD5 you specified is not valid")
+	ErrInvalidPart            = newError(400, "InvalidPart", goose.ErrInvalidPart.Error())
+	ErrInvalidPartOrder       = newError(400, "InvalidPartOrder", goose.ErrInvalidPartOrder.Error())
+	ErrMalformedXML           = newError(400, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema")
+	ErrMissingContentSHA256   = newError(400, "InvalidRequest", "Missing required header for this request: x-amz-content-sha256")
+	ErrNoSuchBucket           = newError(404, "NoSuchBucket", "The specified bucket does not exist")
+	ErrNoSuchKey              = newError(404, "NoSuchKey", "The specified key does not exist")
+	ErrNoSuchUpload           = newError(404, "NoSuchUpload", "The specified multipart upload does not exist")
+	ErrNotImplemented         = newError(501, "NotImplemented", "A header or query you provided implies functionality that is not implemented")
+	ErrQuotaExceeded          = newError(403, "QuotaExceeded", goose.ErrQuotaExceeded.Error())
+	ErrRequestTimeTooSkewed   = newError(403, "RequestTimeTooSkewed", "The difference between the request time and the server's time is too large")
+	ErrUnsupportedSignature   = newError(400, "InvalidRequest", "Please use AWS4-HMAC-SHA256")
+	ErrSignatureDoesNotMatch  = newError(403, "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided")
+	ErrAuthorizationMalformed = newError(400, "AuthorizationHeaderMalformed", "The authorization header is malformed")
+)
+
+// errorResponse is the XML document sent for errors
+type errorResponse struct {
+	XMLName xml.Name `xml:"Error"`
+	*Error
+	Resource  string `xml:"Resource"`
+	RequestID string `xml:"RequestId"`
+}
+
+// toError converts the errors of the goose packages to S3 errors
+func toError(err error) *Error {
+	switch err {
+	case goose.ErrNotFound:
+		// The buckets, uploads and versions not found are reported as such where they are looked up
+		return ErrNoSuchKey
+	case goose.ErrInvalidIDFormat:
+		return ErrInvalidArgument
+	case goose.ErrObjectTooLarge:
+		return ErrEntityTooLarge
+	case goose.ErrQuotaExceeded:
+		return ErrQuotaExceeded
+	case goose.ErrInvalidPart:
+		return ErrInvalidPart
+	case goose.ErrInvalidPartOrder:
+		return ErrInvalidPartOrder
+	case goose.ErrInvalidPartNumber:
+		return newError(400, "InvalidArgument", err.Error())
+	case ghttp.ErrUnauthorized, ghttp.ErrForbidden:
+		return ErrAccessDenied
+	}
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	goose.Log.Error(fmt.Sprintf("error: %T: %v", err, err))
+	return ErrInternalError
+}
+
+// writeError sends an error as an S3 error document. Responses to HEAD requests have no body
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	e := toError(err)
+	if r.Method == "HEAD" {
+		w.WriteHeader(e.Status)
+		return
+	}
+	writeXML(w, e.Status, errorResponse{
+		Error:     e,
+		Resource:  r.URL.Path,
+		RequestID: w.Header().Get("x-amz-request-id"),
+	})
+}
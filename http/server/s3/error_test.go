@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/syb-devs/goose"
+	ghttp "github.com/syb-devs/goose/http"
+)
+
+func TestToError(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected *Error
+	}{
+		{goose.ErrNotFound, ErrNoSuchKey},
+		{goose.ErrInvalidIDFormat, ErrInvalidArgument},
+		{goose.ErrObjectTooLarge, ErrEntityTooLarge},
+		{goose.ErrQuotaExceeded, ErrQuotaExceeded},
+		{ghttp.ErrForbidden, ErrAccessDenied},
+		{ErrNoSuchBucket, ErrNoSuchBucket},
+		{errors.New("disk on fire"), ErrInternalError},
+	}
+	for _, test := range tests {
+		if e := toError(test.err); e != test.expected {
+			t.Errorf("%v: expected %s, got %s", test.err, test.expected.Code, e.Code)
+		}
+	}
+}
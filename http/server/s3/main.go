@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/syb-devs/goose"
+	ghttp "github.com/syb-devs/goose/http"
+)
+
+// uploads keeps the parts of the multipart uploads until they are complete
+var uploads *goose.MultipartStore
+
+// region is the region of the gateway, reported as the location of the buckets
+var region string
+
+func main() {
+	storage, err := goose.StorageFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	goose.SetDefaultStorage(storage)
+
+	creds, err := credentialsFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if authDisabled, err = ghttp.AuthDisabledFromEnv(); err != nil {
+		log.Fatal(err)
+	}
+	if authDisabled {
+		log.Printf("WARNING: authentication is disabled with AUTH_DISABLED, every request has access to everything")
+	} else if creds == nil {
+		log.Fatal("no S3 credentials, set S3_CREDENTIALS, or AUTH_DISABLED=true to run without authentication")
+	}
+	credentials = creds
+	region = envDefault("S3_REGION", "us-east-1")
+
+	uploads, err = goose.NewMultipartStore(envDefault("S3_UPLOAD_PATH", filepath.Join(os.TempDir(), "goose-s3-uploads")))
+	if err != nil {
+		log.Fatal(err)
+	}
+	expiry, err := time.ParseDuration(envDefault("S3_UPLOAD_EXPIRY", "24h"))
+	if err != nil {
+		log.Fatalf("invalid S3_UPLOAD_EXPIRY: %v", err)
+	}
+	go expireUploads(expiry)
+
+	addr := fmt.Sprintf(":%s", envDefault("PORT", "9000"))
+	log.Printf("Goose S3 gateway listening on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, newRouter()))
+}
+
+// expireUploads periodically removes the multipart uploads that were neither completed nor aborted in time
+func expireUploads(maxAge time.Duration) {
+	for range time.Tick(time.Hour) {
+		n, err := uploads.Expire(maxAge)
+		if err != nil {
+			goose.Log.Error(fmt.Sprintf("error expiring multipart uploads: %v", err))
+			continue
+		}
+		if n > 0 {
+			goose.Log.Info(fmt.Sprintf("expired %d multipart uploads", n))
+		}
+	}
+}
+
+func envDefault(key, defval string) string {
+	val := os.Getenv(key)
+	if val != "" {
+		return val
+	}
+	return defval
+}
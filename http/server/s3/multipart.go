@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/syb-devs/goose"
+	ghttp "github.com/syb-devs/goose/http"
+)
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+// createMultipartUpload starts a multipart upload (CreateMultipartUpload)
+func createMultipartUpload(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	bucket, err := findBucket(ctx, goose.PermWrite)
+	if err != nil {
+		return err
+	}
+	key := ctx.URLParams.ByName("key")
+	upload := &goose.MultipartUpload{
+		BucketID:    bucket.ID,
+		Name:        "/" + key,
+		ContentType: r.Header.Get("Content-Type"),
+		Metadata:    *objectMetadata(r.Header),
+		Owner:       ctx.User.Subject,
+	}
+	if err = uploads.Initiate(upload); err != nil {
+		return err
+	}
+	return writeXML(w, 200, initiateMultipartUploadResult{Bucket: bucket.Name, Key: key, UploadID: upload.ID})
+}
+
+// findUpload returns the multipart upload given with the uploadId query parameter, which must be for the
+// requested object
+func findUpload(r *http.Request, ctx *ghttp.Context, bucket *goose.Bucket) (*goose.MultipartUpload, error) {
+	upload, err := uploads.Find(r.URL.Query().Get("uploadId"))
+	if err == goose.ErrNotFound {
+		return nil, ErrNoSuchUpload
+	}
+	if err != nil {
+		return nil, err
+	}
+	if upload.BucketID != bucket.ID || upload.Name != "/"+ctx.URLParams.ByName("key") {
+		return nil, ErrNoSuchUpload
+	}
+	return upload, nil
+}
+
+// uploadPart uploads a part of a multipart upload (UploadPart)
+func uploadPart(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	bucket, err := findBucket(ctx, goose.PermWrite)
+	if err != nil {
+		return err
+	}
+	upload, err := findUpload(r, ctx, bucket)
+	if err != nil {
+		return err
+	}
+	number, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil {
+		return toError(goose.ErrInvalidPartNumber)
+	}
+	data, err := goose.LimitReader(r.Body, r.ContentLength, bucket.Quota.MaxObjectSize)
+	if err != nil {
+		return err
+	}
+	part, err := uploads.PutPart(upload.ID, number, data)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("ETag", strconv.Quote(part.MD5))
+	return nil
+}
+
+type completeMultipartUploadRequest struct {
+	Parts []struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	} `xml:"Part"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CompleteMultipartUploadResult"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+// completeMultipartUpload joins the given parts of a multipart upload into the object
+// (CompleteMultipartUpload). The ETag of the object is the MD5 checksum of its data, as for any other object
+func completeMultipartUpload(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	bucket, err := findBucket(ctx, goose.PermWrite)
+	if err != nil {
+		return err
+	}
+	upload, err := findUpload(r, ctx, bucket)
+	if err != nil {
+		return err
+	}
+	req := completeMultipartUploadRequest{}
+	if err = readXML(r, &req); err != nil {
+		return err
+	}
+	if len(req.Parts) == 0 {
+		return ErrMalformedXML
+	}
+	parts := make([]goose.Part, len(req.Parts))
+	for i, p := range req.Parts {
+		parts[i] = goose.Part{Number: p.PartNumber, MD5: strings.Trim(p.ETag, `"`)}
+	}
+	data, size, err := uploads.Open(upload.ID, parts)
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+
+	obj, err := goose.PutObject(ctx.Storage.Objects(), bucket, data, size, upload.Name, upload.ContentType, &upload.Metadata)
+	if err != nil {
+		return err
+	}
+	if err = uploads.Remove(upload.ID); err != nil {
+		goose.Log.Error(fmt.Sprintf("error removing the completed multipart upload %s: %v", upload.ID, err))
+	}
+	setObjectHeaders(w, bucket, obj)
+	key := ctx.URLParams.ByName("key")
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return writeXML(w, 200, completeMultipartUploadResult{
+		Location: scheme + "://" + r.Host + "/" + bucket.Name + "/" + uriEncode(key, false),
+		Bucket:   bucket.Name,
+		Key:      key,
+		ETag:     strconv.Quote(obj.MD5),
+	})
+}
+
+// abortMultipartUpload discards a multipart upload along with its parts (AbortMultipartUpload)
+func abortMultipartUpload(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	bucket, err := findBucket(ctx, goose.PermWrite)
+	if err != nil {
+		return err
+	}
+	upload, err := findUpload(r, ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if err = uploads.Remove(upload.ID); err != nil && err != goose.ErrNotFound {
+		return err
+	}
+	w.WriteHeader(204)
+	return nil
+}
+
+type partEntry struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+	Size       int64  `xml:"Size"`
+}
+
+type listPartsResult struct {
+	XMLName              xml.Name    `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListPartsResult"`
+	Bucket               string      `xml:"Bucket"`
+	Key                  string      `xml:"Key"`
+	UploadID             string      `xml:"UploadId"`
+	StorageClass         string      `xml:"StorageClass"`
+	PartNumberMarker     int         `xml:"PartNumberMarker"`
+	NextPartNumberMarker int         `xml:"NextPartNumberMarker"`
+	MaxParts             int         `xml:"MaxParts"`
+	IsTruncated          bool        `xml:"IsTruncated"`
+	Parts                []partEntry `xml:"Part"`
+}
+
+// listParts lists the uploaded parts of a multipart upload, by number (ListParts)
+func listParts(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	bucket, err := findBucket(ctx, goose.PermWrite)
+	if err != nil {
+		return err
+	}
+	upload, err := findUpload(r, ctx, bucket)
+	if err != nil {
+		return err
+	}
+	query := r.URL.Query()
+	res := listPartsResult{
+		Bucket:       bucket.Name,
+		Key:          ctx.URLParams.ByName("key"),
+		UploadID:     upload.ID,
+		StorageClass: "STANDARD",
+		MaxParts:     maxKeys,
+	}
+	if v := query.Get("max-parts"); v != "" {
+		if res.MaxParts, err = strconv.Atoi(v); err != nil || res.MaxParts < 0 {
+			return newError(400, "InvalidArgument", "max-parts must be a non negative integer")
+		}
+		if res.MaxParts > maxKeys {
+			res.MaxParts = maxKeys
+		}
+	}
+	if v := query.Get("part-number-marker"); v != "" {
+		if res.PartNumberMarker, err = strconv.Atoi(v); err != nil {
+			return newError(400, "InvalidArgument", "part-number-marker must be an integer")
+		}
+	}
+	parts, err := uploads.Parts(upload.ID)
+	if err != nil {
+		return err
+	}
+	for _, p := range parts {
+		if p.Number <= res.PartNumberMarker {
+			continue
+		}
+		if len(res.Parts) == res.MaxParts {
+			res.IsTruncated = true
+			break
+		}
+		res.Parts = append(res.Parts, partEntry{PartNumber: p.Number, ETag: strconv.Quote(p.MD5), Size: p.Size})
+		res.NextPartNumberMarker = p.Number
+	}
+	return writeXML(w, 200, res)
+}
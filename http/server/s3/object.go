@@ -0,0 +1,340 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/syb-devs/goose"
+	ghttp "github.com/syb-devs/goose/http"
+)
+
+// metaHeaderPrefix is the prefix of the headers with user metadata, kept in the custom metadata of the objects
+const metaHeaderPrefix = "X-Amz-Meta-"
+
+// unsupportedObjectQueries are the query parameters of the object operations not implemented by the gateway
+var unsupportedObjectQueries = []string{
+	"acl", "attributes", "legal-hold", "restore", "retention", "select", "tagging", "torrent",
+}
+
+// responseOverrideQueries are the query parameters overriding the response headers of the downloads
+var responseOverrideQueries = []string{"response-content-type", "response-cache-control", "response-content-disposition"}
+
+// getObject downloads an object (GetObject and HeadObject), or lists the parts of a multipart upload (ListParts).
+// Objects are downloaded by the users allowed by the bucket visibility, like from the file server
+func getObject(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	key := ctx.URLParams.ByName("key")
+	switch {
+	case key == "" && r.Method == "HEAD":
+		return headBucket(w, r, ctx)
+	case key == "":
+		return getBucket(w, r, ctx)
+	case hasQuery(r, "uploadId"):
+		return listParts(w, r, ctx)
+	case hasQuery(r, unsupportedObjectQueries...):
+		return ErrNotImplemented
+	}
+	query := r.URL.Query()
+	// Signed requests, presigned URLs among them, can override some of the response headers
+	if ctx.User == nil && hasQuery(r, responseOverrideQueries...) {
+		return ErrAnonymousOverride
+	}
+	bucket, err := lookupBucket(ctx, ctx.URLParams.ByName("bucket"))
+	if err != nil {
+		return err
+	}
+	if !ctx.User.CanDownload(bucket) {
+		return ErrAccessDenied
+	}
+	obj, err := openObject(ctx, bucket, "/"+key, query.Get("versionId"))
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+
+	setObjectHeaders(w, bucket, obj)
+	if v := query.Get("response-content-type"); v != "" {
+		obj.ContentType = v
+	}
+	if v := query.Get("response-cache-control"); v != "" {
+		obj.Metadata.CacheControl = v
+	}
+	if v := query.Get("response-content-disposition"); v != "" {
+		w.Header().Set("Content-Disposition", v)
+	}
+	ghttp.ServeObject(w, r, obj, bucket.CacheControl)
+	return nil
+}
+
+// lookupBucket finds a bucket by name
+func lookupBucket(ctx *ghttp.Context, name string) (*goose.Bucket, error) {
+	bucket, err := ctx.Storage.Buckets().FindName(name)
+	if err == goose.ErrNotFound {
+		return nil, ErrNoSuchBucket
+	}
+	return bucket, err
+}
+
+// openObject opens the newest version of the named object in the bucket, or the one with the given version ID
+func openObject(ctx *ghttp.Context, bucket *goose.Bucket, name, versionID string) (*goose.Object, error) {
+	repo := ctx.Storage.Objects()
+	if versionID == "" {
+		obj, err := repo.OpenFromBucket(name, bucket.ID)
+		if err == goose.ErrNotFound {
+			return nil, ErrNoSuchKey
+		}
+		return obj, err
+	}
+	if !goose.ValidObjectID(versionID) {
+		return nil, newError(400, "InvalidArgument", "Invalid version id specified")
+	}
+	obj, err := repo.OpenId(versionID)
+	if err == goose.ErrNotFound {
+		return nil, newError(404, "NoSuchVersion", "The specified version does not exist")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if obj.Metadata.BucketID != bucket.ID || obj.Name != name {
+		obj.Close()
+		return nil, newError(404, "NoSuchVersion", "The specified version does not exist")
+	}
+	return obj, nil
+}
+
+// setObjectHeaders sets the user metadata and version headers of an object
+func setObjectHeaders(w http.ResponseWriter, bucket *goose.Bucket, obj *goose.Object) {
+	h := w.Header()
+	if obj.Metadata != nil {
+		for name, value := range obj.Metadata.Custom {
+			if s, ok := value.(string); ok {
+				h.Set(metaHeaderPrefix+name, s)
+			}
+		}
+	}
+	if bucket.Versioning {
+		h.Set("x-amz-version-id", obj.ID.Hex())
+	}
+}
+
+// objectMetadata returns the metadata of an object uploaded with the given headers
+func objectMetadata(h http.Header) *goose.ObjectMetadata {
+	meta := &goose.ObjectMetadata{CacheControl: h.Get("Cache-Control")}
+	for name, values := range h {
+		if strings.HasPrefix(name, metaHeaderPrefix) && len(values) > 0 {
+			if meta.Custom == nil {
+				meta.Custom = make(map[string]interface{})
+			}
+			meta.Custom[strings.ToLower(name[len(metaHeaderPrefix):])] = values[0]
+		}
+	}
+	return meta
+}
+
+// putObject uploads an object (PutObject), copies it (CopyObject) or uploads a part of a multipart
+// upload (UploadPart)
+func putObject(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	key := ctx.URLParams.ByName("key")
+	switch {
+	case key == "":
+		return putBucket(w, r, ctx)
+	case hasQuery(r, "uploadId"):
+		return uploadPart(w, r, ctx)
+	case hasQuery(r, unsupportedObjectQueries...):
+		return ErrNotImplemented
+	case r.Header.Get("X-Amz-Copy-Source") != "":
+		return copyObject(w, r, ctx)
+	}
+	bucket, err := findBucket(ctx, goose.PermWrite)
+	if err != nil {
+		return err
+	}
+	obj, err := goose.PutObject(ctx.Storage.Objects(), bucket, r.Body, r.ContentLength, "/"+key,
+		r.Header.Get("Content-Type"), objectMetadata(r.Header))
+	if err != nil {
+		return err
+	}
+	setObjectHeaders(w, bucket, obj)
+	w.Header().Set("ETag", strconv.Quote(obj.MD5))
+	return nil
+}
+
+type copyObjectResult struct {
+	XMLName      xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CopyObjectResult"`
+	LastModified string   `xml:"LastModified"`
+	ETag         string   `xml:"ETag"`
+}
+
+// copyObject copies the object given in the X-Amz-Copy-Source header, as /<bucket>/<key>[?versionId=<id>]. The
+// metadata of the source object is kept, unless the X-Amz-Metadata-Directive header is REPLACE
+func copyObject(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	source := r.Header.Get("X-Amz-Copy-Source")
+	var versionID string
+	if i := strings.Index(source, "?versionId="); i >= 0 {
+		source, versionID = source[:i], source[i+len("?versionId="):]
+	}
+	source, err := url.PathUnescape(strings.TrimPrefix(source, "/"))
+	parts := strings.SplitN(source, "/", 2)
+	if err != nil || len(parts) != 2 || parts[1] == "" {
+		return newError(400, "InvalidArgument", "Copy Source must mention the source bucket and key: sourcebucket/sourcekey")
+	}
+
+	srcBucket, err := lookupBucket(ctx, parts[0])
+	if err != nil {
+		return err
+	}
+	if !ctx.User.CanDownload(srcBucket) {
+		return ErrAccessDenied
+	}
+	bucket, err := findBucket(ctx, goose.PermWrite)
+	if err != nil {
+		return err
+	}
+	src, err := openObject(ctx, srcBucket, "/"+parts[1], versionID)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	meta, ctype := objectMetadata(r.Header), r.Header.Get("Content-Type")
+	if r.Header.Get("X-Amz-Metadata-Directive") != "REPLACE" {
+		copied := *src.Metadata
+		meta, ctype = &copied, src.ContentType
+	}
+	obj, err := goose.PutObject(ctx.Storage.Objects(), bucket, src.File(), src.Size, "/"+ctx.URLParams.ByName("key"), ctype, meta)
+	if err != nil {
+		return err
+	}
+	setObjectHeaders(w, bucket, obj)
+	return writeXML(w, 200, copyObjectResult{
+		LastModified: formatTime(obj.UploadDate),
+		ETag:         strconv.Quote(obj.MD5),
+	})
+}
+
+// deleteObject deletes an object (DeleteObject), or aborts a multipart upload (AbortMultipartUpload). Goose has
+// no delete markers, so every version of the object is deleted unless a version ID is given
+func deleteObject(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	key := ctx.URLParams.ByName("key")
+	switch {
+	case key == "":
+		return deleteBucket(w, r, ctx)
+	case hasQuery(r, "uploadId"):
+		return abortMultipartUpload(w, r, ctx)
+	case hasQuery(r, unsupportedObjectQueries...):
+		return ErrNotImplemented
+	}
+	bucket, err := findBucket(ctx, goose.PermDelete)
+	if err != nil {
+		return err
+	}
+	if err = deleteVersions(ctx.Storage.Objects(), bucket, "/"+key, r.URL.Query().Get("versionId")); err != nil {
+		return err
+	}
+	w.WriteHeader(204)
+	return nil
+}
+
+// deleteVersions deletes the version of the named object with the given ID, or every version if the ID is empty.
+// Deleting an object that does not exist succeeds
+func deleteVersions(repo goose.ObjectStore, bucket *goose.Bucket, name, versionID string) error {
+	if versionID != "" {
+		if !goose.ValidObjectID(versionID) {
+			return newError(400, "InvalidArgument", "Invalid version id specified")
+		}
+		obj, err := repo.FindId(versionID)
+		if err == goose.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if obj.Metadata.BucketID != bucket.ID || obj.Name != name {
+			return nil
+		}
+		if err = repo.DeleteId(versionID); err == goose.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+	versions, err := repo.FindVersions(bucket.ID, name)
+	if err != nil {
+		return err
+	}
+	versions.Close()
+	for _, version := range versions.Objects() {
+		if err = repo.DeleteId(version.ID.Hex()); err != nil && err != goose.ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+type deleteRequest struct {
+	Quiet   bool `xml:"Quiet"`
+	Objects []struct {
+		Key       string `xml:"Key"`
+		VersionID string `xml:"VersionId"`
+	} `xml:"Object"`
+}
+
+type deletedEntry struct {
+	Key       string `xml:"Key"`
+	VersionID string `xml:"VersionId,omitempty"`
+}
+
+type deleteError struct {
+	Key       string `xml:"Key"`
+	VersionID string `xml:"VersionId,omitempty"`
+	Code      string `xml:"Code"`
+	Message   string `xml:"Message"`
+}
+
+type deleteResult struct {
+	XMLName xml.Name       `xml:"http://s3.amazonaws.com/doc/2006-03-01/ DeleteResult"`
+	Deleted []deletedEntry `xml:"Deleted"`
+	Errors  []deleteError  `xml:"Error"`
+}
+
+// deleteObjects deletes up to a thousand objects (DeleteObjects)
+func deleteObjects(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	bucket, err := findBucket(ctx, goose.PermDelete)
+	if err != nil {
+		return err
+	}
+	req := deleteRequest{}
+	if err = readXML(r, &req); err != nil {
+		return err
+	}
+	if len(req.Objects) == 0 || len(req.Objects) > maxKeys {
+		return ErrMalformedXML
+	}
+	repo := ctx.Storage.Objects()
+	res := deleteResult{}
+	for _, o := range req.Objects {
+		if err = deleteVersions(repo, bucket, "/"+o.Key, o.VersionID); err != nil {
+			e := toError(err)
+			res.Errors = append(res.Errors, deleteError{Key: o.Key, VersionID: o.VersionID, Code: e.Code, Message: e.Message})
+			continue
+		}
+		if !req.Quiet {
+			res.Deleted = append(res.Deleted, deletedEntry{Key: o.Key, VersionID: o.VersionID})
+		}
+	}
+	return writeXML(w, 200, res)
+}
+
+// postObject starts (CreateMultipartUpload) or completes (CompleteMultipartUpload) a multipart upload
+func postObject(w http.ResponseWriter, r *http.Request, ctx *ghttp.Context) error {
+	switch {
+	case ctx.URLParams.ByName("key") == "":
+		return postBucket(w, r, ctx)
+	case hasQuery(r, "uploads"):
+		return createMultipartUpload(w, r, ctx)
+	case hasQuery(r, "uploadId"):
+		return completeMultipartUpload(w, r, ctx)
+	}
+	return ErrNotImplemented
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGetObjectOverrides(t *testing.T) {
+	srv, done := testServer(t, "public", "a.txt")
+	defer done()
+	path := "/public/a.txt?response-content-type=text/html&response-content-disposition=attachment"
+
+	// Authentication is disabled, so the request is made by an admin
+	res, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 || res.Header.Get("Content-Type") != "text/html" || res.Header.Get("Content-Disposition") != "attachment" {
+		t.Errorf("expected the overridden headers, got %d %v", res.StatusCode, res.Header)
+	}
+
+	// Anonymous requests can download from public buckets, but not override the headers
+	authDisabled = false
+	get(t, srv, "/public/a.txt", 200)
+	get(t, srv, path, 400)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/dimfeld/httptreemux"
+	"github.com/syb-devs/goose"
+	ghttp "github.com/syb-devs/goose/http"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// s3Namespace is the XML namespace of the S3 documents
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// newRouter routes the S3 operations, addressing the buckets in the path (path-style requests). S3 tells apart
+// the operations on the same path by their query parameters, so each handler dispatches them
+func newRouter() *httptreemux.TreeMux {
+	rt := httptreemux.New()
+	rt.RedirectTrailingSlash = false
+
+	rt.GET("/", handler(listBuckets))
+
+	rt.PUT("/:bucket", handler(putBucket))
+	rt.HEAD("/:bucket", handler(headBucket))
+	rt.GET("/:bucket", handler(getBucket))
+	rt.POST("/:bucket", handler(postBucket))
+	rt.DELETE("/:bucket", handler(deleteBucket))
+
+	rt.PUT("/:bucket/*key", handler(putObject))
+	rt.HEAD("/:bucket/*key", handler(getObject))
+	rt.GET("/:bucket/*key", handler(getObject))
+	rt.POST("/:bucket/*key", handler(postObject))
+	rt.DELETE("/:bucket/*key", handler(deleteObject))
+
+	return rt
+}
+
+// handler adapts a goose handler to the S3 gateway, authenticating the request with its AWS signature and
+// sending the errors as S3 error documents
+func handler(f ghttp.HandlerFunc) httptreemux.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, ps map[string]string) {
+		goose.Log.Debug(fmt.Sprintf("%v %v", r.Method, r.URL.Path))
+		w.Header().Set("x-amz-request-id", bson.NewObjectId().Hex())
+
+		defer func() {
+			if p := recover(); p != nil {
+				goose.Log.Critical(fmt.Sprintf("recovering from panic: %v. \nstack trace: %s", p, debug.Stack()))
+				writeError(w, r, ErrInternalError)
+			}
+		}()
+
+		ctx := &ghttp.Context{
+			Storage:   goose.DefaultStorage().Copy(),
+			URLParams: ghttp.URLParams(ps),
+		}
+		defer ctx.Storage.Close()
+
+		var err error
+		if ctx.User, err = authenticate(r, ctx.Storage); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if err = f(w, r, ctx); err != nil {
+			writeError(w, r, err)
+		}
+	}
+}
+
+// writeXML sends an S3 XML document with the given status
+func writeXML(w http.ResponseWriter, status int, d interface{}) error {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(d)
+}
+
+// readXML decodes an S3 XML document from the request body
+func readXML(r *http.Request, d interface{}) error {
+	if err := xml.NewDecoder(r.Body).Decode(d); err != nil {
+		return ErrMalformedXML
+	}
+	return nil
+}
+
+// hasQuery tells whether the request has any of the given query parameters, which select the S3 operation
+func hasQuery(r *http.Request, names ...string) bool {
+	query := r.URL.Query()
+	for _, name := range names {
+		if _, ok := query[name]; ok {
+			return true
+		}
+	}
+	return false
+}
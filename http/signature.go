@@ -0,0 +1,158 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoSigningKey is returned when signing or verifying URLs without setting the signing key
+	ErrNoSigningKey = errors.New("the URL signing key is not set")
+	// ErrInvalidSignature is returned when the signature of a URL does not match its parameters
+	ErrInvalidSignature = errors.New("invalid URL signature")
+	// ErrSignatureExpired is returned when a signed URL is used after its expiry time
+	ErrSignatureExpired = errors.New("the signed URL has expired")
+	// ErrSignatureIP is returned when a signed URL restricted to an IP address is used from another one
+	ErrSignatureIP = errors.New("the signed URL is not valid for this IP address")
+)
+
+var signingKey []byte
+
+// SetSigningKey sets the secret key used to sign the URLs. Both the API server, which signs them, and the file
+// server, which verifies them, must use the same key
+func SetSigningKey(key []byte) {
+	signingKey = key
+}
+
+// SigningKeyFromEnv returns the URL signing key set with the URL_SIGNING_KEY env var
+func SigningKeyFromEnv() []byte {
+	return []byte(os.Getenv("URL_SIGNING_KEY"))
+}
+
+var clientIPHeader string
+
+// SetClientIPHeader sets the request header, like X-Forwarded-For or X-Real-IP, holding the client address when
+// the file server is behind a reverse proxy, for the URLs restricted to an IP address. Only set it if the proxy
+// always sets the header, as clients could forge it otherwise. If the header lists several addresses, the last one,
+// added by the proxy, is used
+func SetClientIPHeader(header string) {
+	clientIPHeader = http.CanonicalHeaderKey(header)
+}
+
+// ClientIPHeaderFromEnv returns the client address header set with the CLIENT_IP_HEADER env var
+func ClientIPHeaderFromEnv() string {
+	return os.Getenv("CLIENT_IP_HEADER")
+}
+
+// clientIP returns the address of the client making a request, as given by the client IP header if set, or else
+// by the connection
+func clientIP(r *http.Request) string {
+	if values := r.Header[clientIPHeader]; clientIPHeader != "" && len(values) > 0 {
+		addresses := strings.Split(values[len(values)-1], ",")
+		return strings.TrimSpace(addresses[len(addresses)-1])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// URLSignature holds the parameters of a signed download URL
+type URLSignature struct {
+	// Bucket is the name of the bucket
+	Bucket string
+	// Object is the name of the object
+	Object string
+	// VersionID is the ID of the object version, empty for the newest one
+	VersionID string
+	// Expires is the time after which the URL is no longer valid
+	Expires time.Time
+	// IP, if set, is the only client address allowed to use the URL
+	IP string
+	// Disposition, if set, is sent as the Content-Disposition header of the response
+	Disposition string
+}
+
+// Sign returns the path and query of the file server URL for the signature parameters
+func (s *URLSignature) Sign() (string, error) {
+	if len(signingKey) == 0 {
+		return "", ErrNoSigningKey
+	}
+	query := url.Values{}
+	if s.VersionID != "" {
+		query.Set("versionId", s.VersionID)
+	}
+	query.Set("expires", strconv.FormatInt(s.Expires.Unix(), 10))
+	if s.IP != "" {
+		query.Set("ip", s.IP)
+	}
+	if s.Disposition != "" {
+		query.Set("disposition", s.Disposition)
+	}
+	query.Set("signature", s.mac())
+	path := &url.URL{Path: "/" + s.Bucket + PrefixSlash(s.Object)}
+	return path.EscapedPath() + "?" + query.Encode(), nil
+}
+
+// mac returns the HMAC-SHA256 of the signature parameters, encoded as URL safe base64
+func (s *URLSignature) mac() string {
+	h := hmac.New(sha256.New, signingKey)
+	h.Write([]byte(strings.Join([]string{
+		"GET",
+		s.Bucket,
+		s.Object,
+		s.VersionID,
+		strconv.FormatInt(s.Expires.Unix(), 10),
+		s.IP,
+		s.Disposition,
+	}, "\n")))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// IsSigned tells whether the request has a URL signature
+func IsSigned(r *http.Request) bool {
+	return r.URL.Query().Get("signature") != ""
+}
+
+// VerifySignature checks the signature of a request for an object, returning its parameters if valid. Behind a
+// reverse proxy, the client address of the URLs restricted to an IP address is only known with SetClientIPHeader
+func VerifySignature(r *http.Request, bucket, object string) (*URLSignature, error) {
+	if len(signingKey) == 0 {
+		return nil, ErrNoSigningKey
+	}
+	query := r.URL.Query()
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	s := &URLSignature{
+		Bucket:      bucket,
+		Object:      object,
+		VersionID:   query.Get("versionId"),
+		Expires:     time.Unix(expires, 0),
+		IP:          query.Get("ip"),
+		Disposition: query.Get("disposition"),
+	}
+	if !hmac.Equal([]byte(s.mac()), []byte(query.Get("signature"))) {
+		return nil, ErrInvalidSignature
+	}
+	if time.Now().After(s.Expires) {
+		return nil, ErrSignatureExpired
+	}
+	if s.IP != "" {
+		if !net.ParseIP(clientIP(r)).Equal(net.ParseIP(s.IP)) {
+			return nil, ErrSignatureIP
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,48 @@
+package http
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVerifySignatureIP(t *testing.T) {
+	SetSigningKey([]byte("signature-test-key"))
+	defer SetSigningKey(nil)
+	defer SetClientIPHeader("")
+
+	sig := &URLSignature{Bucket: "docs", Object: "/a.txt", Expires: time.Now().Add(time.Hour), IP: "203.0.113.7"}
+	path, err := sig.Sign()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		header    string
+		remote    string
+		forwarded []string
+		expected  error
+	}{
+		{"", "203.0.113.7:4000", nil, nil},
+		{"", "10.0.0.1:4000", nil, ErrSignatureIP},
+		// Without the header set, the forwarded address is not trusted
+		{"", "10.0.0.1:4000", []string{"203.0.113.7"}, ErrSignatureIP},
+		{"X-Forwarded-For", "10.0.0.1:4000", []string{"203.0.113.7"}, nil},
+		{"x-forwarded-for", "10.0.0.1:4000", []string{"198.51.100.1, 203.0.113.7"}, nil},
+		// Only the address added by the proxy counts, not those sent by the client
+		{"X-Forwarded-For", "10.0.0.1:4000", []string{"203.0.113.7, 198.51.100.1"}, ErrSignatureIP},
+		{"X-Forwarded-For", "10.0.0.1:4000", []string{"203.0.113.7", "198.51.100.1"}, ErrSignatureIP},
+		// Requests not going through the proxy use the connection address
+		{"X-Forwarded-For", "203.0.113.7:4000", nil, nil},
+	}
+	for _, test := range tests {
+		SetClientIPHeader(test.header)
+		r := httptest.NewRequest("GET", path, nil)
+		r.RemoteAddr = test.remote
+		for _, v := range test.forwarded {
+			r.Header.Add("X-Forwarded-For", v)
+		}
+		if _, err = VerifySignature(r, "docs", "/a.txt"); err != test.expected {
+			t.Errorf("%q from %s via %v: expected %v, got %v", test.header, test.remote, test.forwarded, test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,840 @@
+package goose
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	localBucketsDir = "buckets"
+	localObjectsDir = "objects"
+	localAPIKeysDir = "apikeys"
+	localUsersDir   = "users"
+	localRolesDir   = "roles"
+	localMetaExt    = ".meta"
+)
+
+// localStorage keeps the object data in a directory tree under root. Bucket and object records are stored
+// as BSON sidecar files next to the data, and loaded into an in-memory index when the storage is opened. Several
+// processes can share the directory, each one reloading the records changed by the others (see localjournal.go).
+//
+// Layout:
+//
+//	<root>/buckets/<bucketID>.meta
+//	<root>/objects/<xx>/<objectID>       (xx are the last two hex digits of the object ID)
+//	<root>/objects/<xx>/<objectID>.meta
+//	<root>/apikeys/<keyID>.meta
+//	<root>/users/<userID>.meta
+//	<root>/roles/<roleID>.meta
+//	<root>/lock
+//	<root>/journal
+type localStorage struct {
+	root string
+	mu   sync.RWMutex
+	idx  *storeIndex
+
+	// lockFile is locked by the processes changing the storage, which record the changes in the journal
+	lockFile      *os.File
+	journal       *os.File
+	journalOffset int64
+	changes       []string
+}
+
+// NewLocalStorage returns a storage backend that keeps everything under the given directory of the local filesystem
+func NewLocalStorage(root string) (Storage, error) {
+	s := &localStorage{root: root}
+	for _, dir := range []string{localBucketsDir, localObjectsDir, localAPIKeysDir, localUsersDir, localRolesDir} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			return nil, err
+		}
+	}
+	lockFile, err := os.OpenFile(filepath.Join(root, localLockFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s.lockFile = lockFile
+	if err = s.lock(); err != nil {
+		lockFile.Close()
+		return nil, err
+	}
+	s.unlock()
+	return s, nil
+}
+
+func (s *localStorage) Buckets() BucketStore {
+	return &localBucketRepo{s: s}
+}
+
+func (s *localStorage) Objects() ObjectStore {
+	return &localObjectRepo{s: s}
+}
+
+func (s *localStorage) APIKeys() APIKeyStore {
+	return &localAPIKeyRepo{s: s}
+}
+
+func (s *localStorage) Users() UserStore {
+	return &localUserRepo{s: s}
+}
+
+func (s *localStorage) Roles() RoleStore {
+	return &localRoleRepo{s: s}
+}
+
+// Copy returns the same storage, as the local filesystem does not need per-session resources
+func (s *localStorage) Copy() Storage {
+	return s
+}
+
+func (s *localStorage) Close() {}
+
+func (s *localStorage) bucketPath(ID bson.ObjectId) string {
+	return filepath.Join(s.root, localBucketsDir, ID.Hex()+localMetaExt)
+}
+
+// recordPath returns the path of the sidecar file of a record stored in one of the record dirs
+func (s *localStorage) recordPath(dir string, ID bson.ObjectId) string {
+	return filepath.Join(s.root, dir, ID.Hex()+localMetaExt)
+}
+
+func (s *localStorage) apiKeyPath(ID bson.ObjectId) string {
+	return s.recordPath(localAPIKeysDir, ID)
+}
+
+func (s *localStorage) objectPath(ID bson.ObjectId) string {
+	hexID := ID.Hex()
+	return filepath.Join(s.root, localObjectsDir, hexID[len(hexID)-2:], hexID)
+}
+
+// load reads every bucket, object, API key, user and role sidecar file into the index
+func (s *localStorage) load() error {
+	err := s.walkMeta(filepath.Join(s.root, localAPIKeysDir), func(data []byte) error {
+		k := &APIKey{}
+		if err := bson.Unmarshal(data, k); err != nil {
+			return err
+		}
+		s.idx.apiKeys[k.ID] = k
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = s.walkMeta(filepath.Join(s.root, localUsersDir), func(data []byte) error {
+		u := &User{}
+		if err := bson.Unmarshal(data, u); err != nil {
+			return err
+		}
+		s.idx.users[u.ID] = u
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = s.walkMeta(filepath.Join(s.root, localRolesDir), func(data []byte) error {
+		r := &Role{}
+		if err := bson.Unmarshal(data, r); err != nil {
+			return err
+		}
+		s.idx.roles[r.ID] = r
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = s.walkMeta(filepath.Join(s.root, localBucketsDir), func(data []byte) error {
+		b := &Bucket{}
+		if err := bson.Unmarshal(data, b); err != nil {
+			return err
+		}
+		s.idx.putBucket(b)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.walkMeta(filepath.Join(s.root, localObjectsDir), func(data []byte) error {
+		f := &gridFSFile{}
+		if err := bson.Unmarshal(data, f); err != nil {
+			return err
+		}
+		s.idx.putObject(f.object())
+		return nil
+	})
+}
+
+func (s *localStorage) walkMeta(dir string, fn func(data []byte) error) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, localMetaExt) {
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return fn(data)
+	})
+}
+
+// saveBucket writes the sidecar file of a bucket already in the index. A nil bucket is ignored
+func (s *localStorage) saveBucket(b *Bucket) error {
+	if b == nil {
+		return nil
+	}
+	if err := writeMeta(s.bucketPath(b.ID), b); err != nil {
+		return err
+	}
+	s.changed(localBucketsDir, b.ID)
+	return nil
+}
+
+// writeMeta atomically replaces the sidecar file at path with the BSON encoding of doc
+func writeMeta(path string, doc interface{}) error {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func objectFileDoc(o *Object) *gridFSFile {
+	return &gridFSFile{
+		ID:          o.ID,
+		UploadDate:  o.UploadDate,
+		Length:      o.Size,
+		MD5:         o.MD5,
+		Filename:    o.Name,
+		ContentType: o.ContentType,
+		Metadata:    o.Metadata,
+	}
+}
+
+type localBucketRepo struct {
+	s *localStorage
+}
+
+func (r *localBucketRepo) Insert(b *Bucket) error {
+	if err := r.s.lock(); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	if b.ID.Hex() == "" {
+		b.ID = bson.NewObjectId()
+	}
+	if r.s.idx.bucketName(b.Name) != nil {
+		return ErrDuplicateKey
+	}
+	b.Touch()
+	if err := writeMeta(r.s.bucketPath(b.ID), b); err != nil {
+		return err
+	}
+	r.s.changed(localBucketsDir, b.ID)
+	r.s.idx.putBucket(b)
+	return nil
+}
+
+func (r *localBucketRepo) FindId(ID string) (*Bucket, error) {
+	if err := checkObjectId(ID); err != nil {
+		return &Bucket{}, err
+	}
+	if err := r.s.rlock(); err != nil {
+		return nil, err
+	}
+	defer r.s.mu.RUnlock()
+
+	b, ok := r.s.idx.buckets[bson.ObjectIdHex(ID)]
+	if !ok {
+		return &Bucket{}, ErrNotFound
+	}
+	return copyBucket(b), nil
+}
+
+func (r *localBucketRepo) FindName(name string) (*Bucket, error) {
+	if err := r.s.rlock(); err != nil {
+		return nil, err
+	}
+	defer r.s.mu.RUnlock()
+
+	b := r.s.idx.bucketName(name)
+	if b == nil {
+		return &Bucket{}, ErrNotFound
+	}
+	return copyBucket(b), nil
+}
+
+func (r *localBucketRepo) Update(b *Bucket) error {
+	if err := r.s.lock(); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	if _, ok := r.s.idx.buckets[b.ID]; !ok {
+		return ErrNotFound
+	}
+	if other := r.s.idx.bucketName(b.Name); other != nil && other.ID != b.ID {
+		return ErrDuplicateKey
+	}
+	b.Touch()
+	r.s.idx.keepStats(b)
+	if err := writeMeta(r.s.bucketPath(b.ID), b); err != nil {
+		return err
+	}
+	r.s.changed(localBucketsDir, b.ID)
+	r.s.idx.putBucket(b)
+	return nil
+}
+
+func (r *localBucketRepo) DeleteId(ID string) error {
+	if err := checkObjectId(ID); err != nil {
+		return err
+	}
+	if err := r.s.lock(); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	bID := bson.ObjectIdHex(ID)
+	if _, ok := r.s.idx.buckets[bID]; !ok {
+		return ErrNotFound
+	}
+	if err := os.Remove(r.s.bucketPath(bID)); err != nil {
+		return err
+	}
+	r.s.changed(localBucketsDir, bID)
+	delete(r.s.idx.buckets, bID)
+	return nil
+}
+
+func (r *localBucketRepo) Exists(name string) bool {
+	_, err := r.FindName(name)
+	return err == nil
+}
+
+func (r *localBucketRepo) Find(opts BucketListOptions) (*BucketList, error) {
+	if err := r.s.rlock(); err != nil {
+		return nil, err
+	}
+	defer r.s.mu.RUnlock()
+
+	return r.s.idx.findBuckets(opts)
+}
+
+type localObjectRepo struct {
+	s *localStorage
+}
+
+func (r *localObjectRepo) Create(data io.Reader, name, ctype string, metadata *ObjectMetadata) (*Object, error) {
+	if metadata == nil {
+		metadata = &ObjectMetadata{}
+	}
+	o := &Object{
+		ID:          bson.NewObjectId(),
+		Name:        name,
+		ContentType: ctype,
+		Metadata:    metadata,
+	}
+	path := r.s.objectPath(o.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	// Write the data to a temporary file, which is moved into place once complete
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return nil, err
+	}
+	hash := md5.New()
+	o.Size, err = io.Copy(io.MultiWriter(tmp, hash), data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	o.MD5 = hex.EncodeToString(hash.Sum(nil))
+	o.UploadDate = uploadDate()
+
+	if err := r.s.lock(); err != nil {
+		return nil, err
+	}
+	defer r.s.unlock()
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	if err = writeMeta(path+localMetaExt, objectFileDoc(o)); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	r.s.changed(localObjectsDir, o.ID)
+	r.s.idx.putObject(o)
+	if err = r.s.saveBucket(r.s.idx.addStats(o, 1)); err != nil {
+		return nil, err
+	}
+	return copyObject(o), nil
+}
+
+func (r *localObjectRepo) FindId(ID string) (*Object, error) {
+	if err := checkObjectId(ID); err != nil {
+		return nil, err
+	}
+	if err := r.s.rlock(); err != nil {
+		return nil, err
+	}
+	defer r.s.mu.RUnlock()
+
+	o, ok := r.s.idx.objects[bson.ObjectIdHex(ID)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyObject(o), nil
+}
+
+func (r *localObjectRepo) OpenId(ID string) (*Object, error) {
+	o, err := r.FindId(ID)
+	if err != nil {
+		return nil, err
+	}
+	return r.open(o)
+}
+
+func (r *localObjectRepo) OpenFromBucket(name string, bucketID bson.ObjectId) (*Object, error) {
+	if err := r.s.rlock(); err != nil {
+		return nil, err
+	}
+	o := r.s.idx.newestNamed(name, bucketID)
+	r.s.mu.RUnlock()
+
+	if o == nil {
+		return nil, ErrNotFound
+	}
+	return r.open(o)
+}
+
+func (r *localObjectRepo) open(o *Object) (*Object, error) {
+	f, err := os.Open(r.s.objectPath(o.ID))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	o.file = f
+	return o, nil
+}
+
+func (r *localObjectRepo) UpdateMetadata(ID, name string, metadata ObjectMetadata) error {
+	if err := checkObjectId(ID); err != nil {
+		return err
+	}
+	if err := r.s.lock(); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	o, ok := r.s.idx.objects[bson.ObjectIdHex(ID)]
+	if !ok {
+		return ErrNotFound
+	}
+	updated := copyObject(o)
+	updated.Name = name
+	updated.Metadata = &metadata
+	if err := writeMeta(r.s.objectPath(o.ID)+localMetaExt, objectFileDoc(updated)); err != nil {
+		return err
+	}
+	r.s.changed(localObjectsDir, o.ID)
+	r.s.idx.putObject(updated)
+	return nil
+}
+
+func (r *localObjectRepo) DeleteId(ID string) error {
+	if err := checkObjectId(ID); err != nil {
+		return err
+	}
+	if err := r.s.lock(); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	oID := bson.ObjectIdHex(ID)
+	o, ok := r.s.idx.objects[oID]
+	if !ok {
+		return ErrNotFound
+	}
+	path := r.s.objectPath(oID)
+	if err := os.Remove(path + localMetaExt); err != nil {
+		return err
+	}
+	r.s.changed(localObjectsDir, oID)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(r.s.idx.objects, oID)
+	return r.s.saveBucket(r.s.idx.addStats(o, -1))
+}
+
+func (r *localObjectRepo) RecomputeBucketStats(bucketID bson.ObjectId) error {
+	if err := r.s.lock(); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	b, err := r.s.idx.recomputeStats(bucketID)
+	if err != nil {
+		return err
+	}
+	return r.s.saveBucket(b)
+}
+
+func (r *localObjectRepo) FindByBucket(bucketID bson.ObjectId, opts ListOptions) (*ObjectList, error) {
+	if err := r.s.rlock(); err != nil {
+		return nil, err
+	}
+	defer r.s.mu.RUnlock()
+
+	return r.s.idx.find(func(o *Object) bool { return o.Metadata.BucketID == bucketID }, opts)
+}
+
+func (r *localObjectRepo) FindVersions(bucketID bson.ObjectId, name string) (*ObjectList, error) {
+	if err := r.s.rlock(); err != nil {
+		return nil, err
+	}
+	defer r.s.mu.RUnlock()
+
+	return r.s.idx.list(func(o *Object) bool { return o.Name == name && o.Metadata.BucketID == bucketID }), nil
+}
+
+func (r *localObjectRepo) FindByIds(ids []string) (*ObjectList, error) {
+	wanted := make(map[bson.ObjectId]bool, len(ids))
+	for _, id := range ids {
+		if err := checkObjectId(id); err != nil {
+			return nil, err
+		}
+		wanted[bson.ObjectIdHex(id)] = true
+	}
+	if err := r.s.rlock(); err != nil {
+		return nil, err
+	}
+	defer r.s.mu.RUnlock()
+
+	return r.s.idx.list(func(o *Object) bool { return wanted[o.ID] }), nil
+}
+
+type localAPIKeyRepo struct {
+	s *localStorage
+}
+
+func (r *localAPIKeyRepo) Insert(k *APIKey) error {
+	if err := r.s.lock(); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	if err := r.s.idx.insertAPIKey(k); err != nil {
+		return err
+	}
+	if err := writeMeta(r.s.apiKeyPath(k.ID), k); err != nil {
+		delete(r.s.idx.apiKeys, k.ID)
+		return err
+	}
+	r.s.changed(localAPIKeysDir, k.ID)
+	return nil
+}
+
+func (r *localAPIKeyRepo) FindId(ID string) (*APIKey, error) {
+	if err := checkObjectId(ID); err != nil {
+		return &APIKey{}, err
+	}
+	if err := r.s.rlock(); err != nil {
+		return nil, err
+	}
+	defer r.s.mu.RUnlock()
+
+	k, ok := r.s.idx.apiKeys[bson.ObjectIdHex(ID)]
+	if !ok {
+		return &APIKey{}, ErrNotFound
+	}
+	return copyAPIKey(k), nil
+}
+
+func (r *localAPIKeyRepo) Find(owner string) ([]*APIKey, error) {
+	if err := r.s.rlock(); err != nil {
+		return nil, err
+	}
+	defer r.s.mu.RUnlock()
+
+	return r.s.idx.findAPIKeys(owner), nil
+}
+
+func (r *localAPIKeyRepo) Touch(ID string, lastUsed time.Time) error {
+	if err := checkObjectId(ID); err != nil {
+		return err
+	}
+	if err := r.s.lock(); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	k, ok := r.s.idx.apiKeys[bson.ObjectIdHex(ID)]
+	if !ok {
+		return ErrNotFound
+	}
+	updated := copyAPIKey(k)
+	updated.LastUsed = &lastUsed
+	if err := writeMeta(r.s.apiKeyPath(k.ID), updated); err != nil {
+		return err
+	}
+	r.s.changed(localAPIKeysDir, k.ID)
+	r.s.idx.apiKeys[k.ID] = updated
+	return nil
+}
+
+func (r *localAPIKeyRepo) DeleteId(ID string) error {
+	if err := checkObjectId(ID); err != nil {
+		return err
+	}
+	if err := r.s.lock(); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	kID := bson.ObjectIdHex(ID)
+	if _, ok := r.s.idx.apiKeys[kID]; !ok {
+		return ErrNotFound
+	}
+	if err := os.Remove(r.s.apiKeyPath(kID)); err != nil {
+		return err
+	}
+	r.s.changed(localAPIKeysDir, kID)
+	delete(r.s.idx.apiKeys, kID)
+	return nil
+}
+
+type localUserRepo struct {
+	s *localStorage
+}
+
+func (r *localUserRepo) Insert(u *User) error {
+	if err := r.s.lock(); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	if err := r.s.idx.insertUser(u); err != nil {
+		return err
+	}
+	if err := writeMeta(r.s.recordPath(localUsersDir, u.ID), u); err != nil {
+		delete(r.s.idx.users, u.ID)
+		return err
+	}
+	r.s.changed(localUsersDir, u.ID)
+	return nil
+}
+
+func (r *localUserRepo) FindId(ID string) (*User, error) {
+	if err := checkObjectId(ID); err != nil {
+		return &User{}, err
+	}
+	if err := r.s.rlock(); err != nil {
+		return nil, err
+	}
+	defer r.s.mu.RUnlock()
+
+	u, ok := r.s.idx.users[bson.ObjectIdHex(ID)]
+	if !ok {
+		return &User{}, ErrNotFound
+	}
+	return copyUser(u), nil
+}
+
+func (r *localUserRepo) FindSubject(subject string) (*User, error) {
+	if err := r.s.rlock(); err != nil {
+		return nil, err
+	}
+	defer r.s.mu.RUnlock()
+
+	u := r.s.idx.userSubject(subject)
+	if u == nil {
+		return &User{}, ErrNotFound
+	}
+	return copyUser(u), nil
+}
+
+func (r *localUserRepo) Update(u *User) error {
+	if err := r.s.lock(); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	old, ok := r.s.idx.users[u.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := r.s.idx.updateUser(u); err != nil {
+		return err
+	}
+	if err := writeMeta(r.s.recordPath(localUsersDir, u.ID), u); err != nil {
+		r.s.idx.users[u.ID] = old
+		return err
+	}
+	r.s.changed(localUsersDir, u.ID)
+	return nil
+}
+
+func (r *localUserRepo) DeleteId(ID string) error {
+	if err := checkObjectId(ID); err != nil {
+		return err
+	}
+	if err := r.s.lock(); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	uID := bson.ObjectIdHex(ID)
+	if _, ok := r.s.idx.users[uID]; !ok {
+		return ErrNotFound
+	}
+	if err := os.Remove(r.s.recordPath(localUsersDir, uID)); err != nil {
+		return err
+	}
+	r.s.changed(localUsersDir, uID)
+	delete(r.s.idx.users, uID)
+	return nil
+}
+
+func (r *localUserRepo) Find() ([]*User, error) {
+	if err := r.s.rlock(); err != nil {
+		return nil, err
+	}
+	defer r.s.mu.RUnlock()
+
+	return r.s.idx.findUsers(), nil
+}
+
+type localRoleRepo struct {
+	s *localStorage
+}
+
+func (r *localRoleRepo) Insert(role *Role) error {
+	if err := r.s.lock(); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	if err := r.s.idx.insertRole(role); err != nil {
+		return err
+	}
+	if err := writeMeta(r.s.recordPath(localRolesDir, role.ID), role); err != nil {
+		delete(r.s.idx.roles, role.ID)
+		return err
+	}
+	r.s.changed(localRolesDir, role.ID)
+	return nil
+}
+
+func (r *localRoleRepo) FindId(ID string) (*Role, error) {
+	if err := checkObjectId(ID); err != nil {
+		return &Role{}, err
+	}
+	if err := r.s.rlock(); err != nil {
+		return nil, err
+	}
+	defer r.s.mu.RUnlock()
+
+	role, ok := r.s.idx.roles[bson.ObjectIdHex(ID)]
+	if !ok {
+		return &Role{}, ErrNotFound
+	}
+	return copyRole(role), nil
+}
+
+func (r *localRoleRepo) FindName(name string) (*Role, error) {
+	if err := r.s.rlock(); err != nil {
+		return nil, err
+	}
+	defer r.s.mu.RUnlock()
+
+	role := r.s.idx.roleName(name)
+	if role == nil {
+		return &Role{}, ErrNotFound
+	}
+	return copyRole(role), nil
+}
+
+func (r *localRoleRepo) Update(role *Role) error {
+	if err := r.s.lock(); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	old, ok := r.s.idx.roles[role.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := r.s.idx.updateRole(role); err != nil {
+		return err
+	}
+	if err := writeMeta(r.s.recordPath(localRolesDir, role.ID), role); err != nil {
+		r.s.idx.roles[role.ID] = old
+		return err
+	}
+	r.s.changed(localRolesDir, role.ID)
+	return nil
+}
+
+func (r *localRoleRepo) DeleteId(ID string) error {
+	if err := checkObjectId(ID); err != nil {
+		return err
+	}
+	if err := r.s.lock(); err != nil {
+		return err
+	}
+	defer r.s.unlock()
+
+	rID := bson.ObjectIdHex(ID)
+	if _, ok := r.s.idx.roles[rID]; !ok {
+		return ErrNotFound
+	}
+	if err := os.Remove(r.s.recordPath(localRolesDir, rID)); err != nil {
+		return err
+	}
+	r.s.changed(localRolesDir, rID)
+	delete(r.s.idx.roles, rID)
+	return nil
+}
+
+func (r *localRoleRepo) Find() ([]*Role, error) {
+	if err := r.s.rlock(); err != nil {
+		return nil, err
+	}
+	defer r.s.mu.RUnlock()
+
+	return r.s.idx.findRoles(), nil
+}
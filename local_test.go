@@ -0,0 +1,116 @@
+package goose
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorageShared(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goose-local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Two storages on the same directory behave like two processes sharing it
+	api, err := NewLocalStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := NewLocalStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &Bucket{Name: "shared"}
+	if err = api.Buckets().Insert(b); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = file.Buckets().FindName("shared"); err != nil {
+		t.Fatalf("bucket created by the other storage not found: %v", err)
+	}
+	if err = file.Buckets().Insert(&Bucket{Name: "shared"}); err != ErrDuplicateKey {
+		t.Fatalf("expected ErrDuplicateKey, got %v", err)
+	}
+
+	o1, err := api.Objects().Create(strings.NewReader("hello"), "/a.txt", "text/plain", &ObjectMetadata{BucketID: b.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = file.Objects().Create(strings.NewReader("world!"), "/b.txt", "text/plain", &ObjectMetadata{BucketID: b.ID}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []Storage{api, file} {
+		found, err := s.Buckets().FindId(b.ID.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found.Objects != 2 || found.Size != 11 {
+			t.Errorf("expected 2 objects of 11 bytes, got %d objects of %d bytes", found.Objects, found.Size)
+		}
+	}
+
+	o, err := file.Objects().OpenFromBucket("/a.txt", b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(o.File())
+	o.Close()
+	if string(data) != "hello" {
+		t.Errorf("expected hello, got %q", data)
+	}
+
+	if err = file.Objects().DeleteId(o1.ID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = api.Objects().FindId(o1.ID.Hex()); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound for the object deleted by the other storage, got %v", err)
+	}
+	found, err := api.Buckets().FindId(b.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Objects != 1 || found.Size != 6 {
+		t.Errorf("expected 1 object of 6 bytes, got %d objects of %d bytes", found.Objects, found.Size)
+	}
+}
+
+func TestLocalStorageJournalStartedOver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goose-local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s1, err := NewLocalStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := NewLocalStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &Bucket{Name: "before"}
+	if err = s1.Buckets().Insert(b); err != nil {
+		t.Fatal(err)
+	}
+
+	// Filling the journal makes the next change start it over
+	ls := s1.(*localStorage)
+	ls.lock()
+	ls.changes = append(ls.changes, strings.Repeat("x", localJournalMaxSize)+"\n")
+	ls.unlock()
+	if err = s1.Buckets().Insert(&Bucket{Name: "after"}); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(ls.journalPath()); err != nil || info.Size() >= localJournalMaxSize {
+		t.Fatalf("expected the journal to be started over: %v %v", info, err)
+	}
+	for _, name := range []string{"before", "after"} {
+		if _, err = s2.Buckets().FindName(name); err != nil {
+			t.Errorf("bucket %s not found after the journal was started over: %v", name, err)
+		}
+	}
+}
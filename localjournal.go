@@ -0,0 +1,257 @@
+package goose
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	localLockFile    = "lock"
+	localJournalFile = "journal"
+	// localJournalMaxSize is the size over which the journal is started over
+	localJournalMaxSize = 1 << 20
+)
+
+// The processes sharing a local storage directory, like the API and file servers, keep their index up to date
+// through the journal, where each change appends a line with the record dir and ID. Before using its index, a
+// process reads the lines written since it last did, reloading those records. Changes are made holding an
+// exclusive lock on the lock file, so each process sees the ones made by the others, and the bucket statistics
+// are not overwritten with stale values.
+
+// lock takes the storage for a change, in this and the other processes, bringing the index up to date first.
+// Release it with unlock
+func (s *localStorage) lock() error {
+	s.mu.Lock()
+	if err := lockFile(s.lockFile, true, true); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	if err := s.sync(); err != nil {
+		s.unlock()
+		return err
+	}
+	return nil
+}
+
+// unlock records the changes made in the journal and releases the storage taken with lock
+func (s *localStorage) unlock() {
+	if err := s.writeJournal(); err != nil {
+		Log.Error(fmt.Sprintf("error writing the local storage journal: %v", err))
+	}
+	unlockFile(s.lockFile)
+	s.mu.Unlock()
+}
+
+// rlock takes the storage for reading, bringing the index up to date first if other processes changed it.
+// Release it with s.mu.RUnlock
+func (s *localStorage) rlock() error {
+	s.mu.RLock()
+	if current, err := s.synced(); current || err != nil {
+		if err != nil {
+			s.mu.RUnlock()
+		}
+		return err
+	}
+	s.mu.RUnlock()
+
+	s.mu.Lock()
+	err := lockFile(s.lockFile, false, true)
+	if err == nil {
+		err = s.sync()
+		unlockFile(s.lockFile)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	s.mu.RLock()
+	return nil
+}
+
+// changed records that the record with the given ID, in one of the record dirs, has been changed
+func (s *localStorage) changed(dir string, ID bson.ObjectId) {
+	s.changes = append(s.changes, dir+" "+ID.Hex()+"\n")
+}
+
+func (s *localStorage) journalPath() string {
+	return filepath.Join(s.root, localJournalFile)
+}
+
+// synced tells whether the index has every change in the journal
+func (s *localStorage) synced() (bool, error) {
+	info, err := os.Stat(s.journalPath())
+	if err != nil {
+		return false, err
+	}
+	opened, err := s.journal.Stat()
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(info, opened) && info.Size() == s.journalOffset, nil
+}
+
+// sync reloads the records changed by other processes since the index was last brought up to date. If the journal
+// was started over, the whole index is reloaded
+func (s *localStorage) sync() error {
+	info, err := os.Stat(s.journalPath())
+	if s.journal == nil || os.IsNotExist(err) {
+		return s.reload()
+	}
+	if err != nil {
+		return err
+	}
+	opened, err := s.journal.Stat()
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(info, opened) || info.Size() < s.journalOffset {
+		return s.reload()
+	}
+	if info.Size() == s.journalOffset {
+		return nil
+	}
+	data := make([]byte, info.Size()-s.journalOffset)
+	if _, err = s.journal.ReadAt(data, s.journalOffset); err != nil {
+		return err
+	}
+	s.journalOffset = info.Size()
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || !bson.IsObjectIdHex(fields[1]) {
+			continue
+		}
+		if err = s.reloadRecord(fields[0], bson.ObjectIdHex(fields[1])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reload reads the whole index again, from the current journal
+func (s *localStorage) reload() error {
+	journal, err := os.OpenFile(s.journalPath(), os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := journal.Stat()
+	if err != nil {
+		journal.Close()
+		return err
+	}
+	if s.journal != nil {
+		s.journal.Close()
+	}
+	s.journal, s.journalOffset = journal, info.Size()
+	s.idx = newStoreIndex()
+	return s.load()
+}
+
+// reloadRecord reads the sidecar file of a record into the index, or removes the record if there is none
+func (s *localStorage) reloadRecord(dir string, ID bson.ObjectId) error {
+	path := s.recordPath(dir, ID)
+	if dir == localObjectsDir {
+		path = s.objectPath(ID) + localMetaExt
+	}
+	data, err := ioutil.ReadFile(path)
+	deleted := os.IsNotExist(err)
+	if deleted {
+		err = nil
+	} else if err != nil {
+		return err
+	}
+
+	switch dir {
+	case localBucketsDir:
+		b := &Bucket{}
+		if deleted {
+			delete(s.idx.buckets, ID)
+		} else if err = bson.Unmarshal(data, b); err == nil {
+			s.idx.putBucket(b)
+		}
+	case localObjectsDir:
+		f := &gridFSFile{}
+		if deleted {
+			delete(s.idx.objects, ID)
+		} else if err = bson.Unmarshal(data, f); err == nil {
+			s.idx.putObject(f.object())
+		}
+	case localAPIKeysDir:
+		k := &APIKey{}
+		if deleted {
+			delete(s.idx.apiKeys, ID)
+		} else if err = bson.Unmarshal(data, k); err == nil {
+			s.idx.apiKeys[ID] = k
+		}
+	case localUsersDir:
+		u := &User{}
+		if deleted {
+			delete(s.idx.users, ID)
+		} else if err = bson.Unmarshal(data, u); err == nil {
+			s.idx.users[ID] = u
+		}
+	case localRolesDir:
+		r := &Role{}
+		if deleted {
+			delete(s.idx.roles, ID)
+		} else if err = bson.Unmarshal(data, r); err == nil {
+			s.idx.roles[ID] = r
+		}
+	}
+	return err
+}
+
+// writeJournal appends the changes made while holding the lock to the journal, starting it over when it grows
+// too large
+func (s *localStorage) writeJournal() error {
+	if len(s.changes) == 0 {
+		return nil
+	}
+	data := strings.Join(s.changes, "")
+	s.changes = s.changes[:0]
+
+	if s.journalOffset+int64(len(data)) > localJournalMaxSize {
+		// The other processes notice the new journal and reload their whole index. Where the journal can not be
+		// replaced while open, as on Windows, the changes are appended to the old one instead
+		err := s.startJournal()
+		if err == nil {
+			return nil
+		}
+		Log.Error(fmt.Sprintf("error starting the local storage journal over: %v", err))
+	}
+
+	f, err := os.OpenFile(s.journalPath(), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	n, err := f.WriteString(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	s.journalOffset += int64(n)
+	return err
+}
+
+// startJournal replaces the journal with an empty one
+func (s *localStorage) startJournal() error {
+	tmp, err := ioutil.TempFile(s.root, ".tmp-")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	if err = os.Rename(tmp.Name(), s.journalPath()); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	journal, err := os.Open(s.journalPath())
+	if err != nil {
+		return err
+	}
+	s.journal.Close()
+	s.journal, s.journalOffset = journal, 0
+	return nil
+}
@@ -0,0 +1,846 @@
+package goose
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// memoryStorage keeps buckets, objects and their data in memory. Everything is lost when the process exits,
+// so it is meant for tests and throwaway development servers.
+type memoryStorage struct {
+	mu   sync.RWMutex
+	idx  *storeIndex
+	data map[bson.ObjectId][]byte
+}
+
+// NewMemoryStorage returns an empty storage backend that keeps everything in memory
+func NewMemoryStorage() Storage {
+	return &memoryStorage{idx: newStoreIndex(), data: make(map[bson.ObjectId][]byte)}
+}
+
+func (s *memoryStorage) Buckets() BucketStore {
+	return &memoryBucketRepo{s: s}
+}
+
+func (s *memoryStorage) Objects() ObjectStore {
+	return &memoryObjectRepo{s: s}
+}
+
+func (s *memoryStorage) APIKeys() APIKeyStore {
+	return &memoryAPIKeyRepo{s: s}
+}
+
+func (s *memoryStorage) Users() UserStore {
+	return &memoryUserRepo{s: s}
+}
+
+func (s *memoryStorage) Roles() RoleStore {
+	return &memoryRoleRepo{s: s}
+}
+
+// Copy returns the same storage, so every session shares the stored data
+func (s *memoryStorage) Copy() Storage {
+	return s
+}
+
+func (s *memoryStorage) Close() {}
+
+type memoryBucketRepo struct {
+	s *memoryStorage
+}
+
+func (r *memoryBucketRepo) Insert(b *Bucket) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if b.ID.Hex() == "" {
+		b.ID = bson.NewObjectId()
+	}
+	if _, ok := r.s.idx.buckets[b.ID]; ok {
+		return ErrDuplicateKey
+	}
+	if r.s.idx.bucketName(b.Name) != nil {
+		return ErrDuplicateKey
+	}
+	b.Touch()
+	r.s.idx.putBucket(b)
+	return nil
+}
+
+func (r *memoryBucketRepo) FindId(ID string) (*Bucket, error) {
+	if err := checkObjectId(ID); err != nil {
+		return &Bucket{}, err
+	}
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	b, ok := r.s.idx.buckets[bson.ObjectIdHex(ID)]
+	if !ok {
+		return &Bucket{}, ErrNotFound
+	}
+	return copyBucket(b), nil
+}
+
+func (r *memoryBucketRepo) FindName(name string) (*Bucket, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	b := r.s.idx.bucketName(name)
+	if b == nil {
+		return &Bucket{}, ErrNotFound
+	}
+	return copyBucket(b), nil
+}
+
+func (r *memoryBucketRepo) Update(b *Bucket) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.s.idx.buckets[b.ID]; !ok {
+		return ErrNotFound
+	}
+	if other := r.s.idx.bucketName(b.Name); other != nil && other.ID != b.ID {
+		return ErrDuplicateKey
+	}
+	b.Touch()
+	r.s.idx.keepStats(b)
+	r.s.idx.putBucket(b)
+	return nil
+}
+
+func (r *memoryBucketRepo) DeleteId(ID string) error {
+	if err := checkObjectId(ID); err != nil {
+		return err
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	bID := bson.ObjectIdHex(ID)
+	if _, ok := r.s.idx.buckets[bID]; !ok {
+		return ErrNotFound
+	}
+	delete(r.s.idx.buckets, bID)
+	return nil
+}
+
+func (r *memoryBucketRepo) Exists(name string) bool {
+	_, err := r.FindName(name)
+	return err == nil
+}
+
+func (r *memoryBucketRepo) Find(opts BucketListOptions) (*BucketList, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	return r.s.idx.findBuckets(opts)
+}
+
+type memoryObjectRepo struct {
+	s *memoryStorage
+}
+
+func (r *memoryObjectRepo) Create(data io.Reader, name, ctype string, metadata *ObjectMetadata) (*Object, error) {
+	if metadata == nil {
+		metadata = &ObjectMetadata{}
+	}
+	buf, err := ioutil.ReadAll(data)
+	if err != nil {
+		return nil, err
+	}
+	sum := md5.Sum(buf)
+	o := &Object{
+		ID:          bson.NewObjectId(),
+		UploadDate:  uploadDate(),
+		Size:        int64(len(buf)),
+		MD5:         hex.EncodeToString(sum[:]),
+		Name:        name,
+		ContentType: ctype,
+		Metadata:    metadata,
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.data[o.ID] = buf
+	r.s.idx.putObject(o)
+	r.s.idx.addStats(o, 1)
+	return copyObject(o), nil
+}
+
+func (r *memoryObjectRepo) FindId(ID string) (*Object, error) {
+	if err := checkObjectId(ID); err != nil {
+		return nil, err
+	}
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	o, ok := r.s.idx.objects[bson.ObjectIdHex(ID)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyObject(o), nil
+}
+
+func (r *memoryObjectRepo) OpenId(ID string) (*Object, error) {
+	o, err := r.FindId(ID)
+	if err != nil {
+		return nil, err
+	}
+	return r.open(o)
+}
+
+func (r *memoryObjectRepo) OpenFromBucket(name string, bucketID bson.ObjectId) (*Object, error) {
+	r.s.mu.RLock()
+	o := r.s.idx.newestNamed(name, bucketID)
+	r.s.mu.RUnlock()
+
+	if o == nil {
+		return nil, ErrNotFound
+	}
+	return r.open(o)
+}
+
+func (r *memoryObjectRepo) open(o *Object) (*Object, error) {
+	r.s.mu.RLock()
+	buf, ok := r.s.data[o.ID]
+	r.s.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+	o.file = memoryFile{bytes.NewReader(buf)}
+	return o, nil
+}
+
+func (r *memoryObjectRepo) UpdateMetadata(ID, name string, metadata ObjectMetadata) error {
+	if err := checkObjectId(ID); err != nil {
+		return err
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	o, ok := r.s.idx.objects[bson.ObjectIdHex(ID)]
+	if !ok {
+		return ErrNotFound
+	}
+	o.Name = name
+	o.Metadata = &metadata
+	return nil
+}
+
+func (r *memoryObjectRepo) DeleteId(ID string) error {
+	if err := checkObjectId(ID); err != nil {
+		return err
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	oID := bson.ObjectIdHex(ID)
+	o, ok := r.s.idx.objects[oID]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(r.s.idx.objects, oID)
+	delete(r.s.data, oID)
+	r.s.idx.addStats(o, -1)
+	return nil
+}
+
+func (r *memoryObjectRepo) RecomputeBucketStats(bucketID bson.ObjectId) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	_, err := r.s.idx.recomputeStats(bucketID)
+	return err
+}
+
+func (r *memoryObjectRepo) FindByBucket(bucketID bson.ObjectId, opts ListOptions) (*ObjectList, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	return r.s.idx.find(func(o *Object) bool { return o.Metadata.BucketID == bucketID }, opts)
+}
+
+func (r *memoryObjectRepo) FindVersions(bucketID bson.ObjectId, name string) (*ObjectList, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	return r.s.idx.list(func(o *Object) bool { return o.Name == name && o.Metadata.BucketID == bucketID }), nil
+}
+
+func (r *memoryObjectRepo) FindByIds(ids []string) (*ObjectList, error) {
+	wanted := make(map[bson.ObjectId]bool, len(ids))
+	for _, id := range ids {
+		if err := checkObjectId(id); err != nil {
+			return nil, err
+		}
+		wanted[bson.ObjectIdHex(id)] = true
+	}
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	return r.s.idx.list(func(o *Object) bool { return wanted[o.ID] }), nil
+}
+
+// memoryFile is the data stream of an object held in memory
+type memoryFile struct {
+	*bytes.Reader
+}
+
+func (f memoryFile) Close() error {
+	return nil
+}
+
+type memoryAPIKeyRepo struct {
+	s *memoryStorage
+}
+
+func (r *memoryAPIKeyRepo) Insert(k *APIKey) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.s.idx.insertAPIKey(k)
+}
+
+func (r *memoryAPIKeyRepo) FindId(ID string) (*APIKey, error) {
+	if err := checkObjectId(ID); err != nil {
+		return &APIKey{}, err
+	}
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	k, ok := r.s.idx.apiKeys[bson.ObjectIdHex(ID)]
+	if !ok {
+		return &APIKey{}, ErrNotFound
+	}
+	return copyAPIKey(k), nil
+}
+
+func (r *memoryAPIKeyRepo) Find(owner string) ([]*APIKey, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	return r.s.idx.findAPIKeys(owner), nil
+}
+
+func (r *memoryAPIKeyRepo) Touch(ID string, lastUsed time.Time) error {
+	if err := checkObjectId(ID); err != nil {
+		return err
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	k, ok := r.s.idx.apiKeys[bson.ObjectIdHex(ID)]
+	if !ok {
+		return ErrNotFound
+	}
+	updated := copyAPIKey(k)
+	updated.LastUsed = &lastUsed
+	r.s.idx.apiKeys[k.ID] = updated
+	return nil
+}
+
+func (r *memoryAPIKeyRepo) DeleteId(ID string) error {
+	if err := checkObjectId(ID); err != nil {
+		return err
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	kID := bson.ObjectIdHex(ID)
+	if _, ok := r.s.idx.apiKeys[kID]; !ok {
+		return ErrNotFound
+	}
+	delete(r.s.idx.apiKeys, kID)
+	return nil
+}
+
+type memoryUserRepo struct {
+	s *memoryStorage
+}
+
+func (r *memoryUserRepo) Insert(u *User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.s.idx.insertUser(u)
+}
+
+func (r *memoryUserRepo) FindId(ID string) (*User, error) {
+	if err := checkObjectId(ID); err != nil {
+		return &User{}, err
+	}
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	u, ok := r.s.idx.users[bson.ObjectIdHex(ID)]
+	if !ok {
+		return &User{}, ErrNotFound
+	}
+	return copyUser(u), nil
+}
+
+func (r *memoryUserRepo) FindSubject(subject string) (*User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	u := r.s.idx.userSubject(subject)
+	if u == nil {
+		return &User{}, ErrNotFound
+	}
+	return copyUser(u), nil
+}
+
+func (r *memoryUserRepo) Update(u *User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.s.idx.updateUser(u)
+}
+
+func (r *memoryUserRepo) DeleteId(ID string) error {
+	if err := checkObjectId(ID); err != nil {
+		return err
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	uID := bson.ObjectIdHex(ID)
+	if _, ok := r.s.idx.users[uID]; !ok {
+		return ErrNotFound
+	}
+	delete(r.s.idx.users, uID)
+	return nil
+}
+
+func (r *memoryUserRepo) Find() ([]*User, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	return r.s.idx.findUsers(), nil
+}
+
+type memoryRoleRepo struct {
+	s *memoryStorage
+}
+
+func (r *memoryRoleRepo) Insert(role *Role) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.s.idx.insertRole(role)
+}
+
+func (r *memoryRoleRepo) FindId(ID string) (*Role, error) {
+	if err := checkObjectId(ID); err != nil {
+		return &Role{}, err
+	}
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	role, ok := r.s.idx.roles[bson.ObjectIdHex(ID)]
+	if !ok {
+		return &Role{}, ErrNotFound
+	}
+	return copyRole(role), nil
+}
+
+func (r *memoryRoleRepo) FindName(name string) (*Role, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	role := r.s.idx.roleName(name)
+	if role == nil {
+		return &Role{}, ErrNotFound
+	}
+	return copyRole(role), nil
+}
+
+func (r *memoryRoleRepo) Update(role *Role) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.s.idx.updateRole(role)
+}
+
+func (r *memoryRoleRepo) DeleteId(ID string) error {
+	if err := checkObjectId(ID); err != nil {
+		return err
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	rID := bson.ObjectIdHex(ID)
+	if _, ok := r.s.idx.roles[rID]; !ok {
+		return ErrNotFound
+	}
+	delete(r.s.idx.roles, rID)
+	return nil
+}
+
+func (r *memoryRoleRepo) Find() ([]*Role, error) {
+	r.s.mu.RLock()
+	defer r.s.mu.RUnlock()
+
+	return r.s.idx.findRoles(), nil
+}
+
+// storeIndex holds the bucket and object records of the drivers that keep them in memory.
+// It is not safe for concurrent use, callers must synchronize the access.
+type storeIndex struct {
+	buckets map[bson.ObjectId]*Bucket
+	objects map[bson.ObjectId]*Object
+	apiKeys map[bson.ObjectId]*APIKey
+	users   map[bson.ObjectId]*User
+	roles   map[bson.ObjectId]*Role
+}
+
+func newStoreIndex() *storeIndex {
+	return &storeIndex{
+		buckets: make(map[bson.ObjectId]*Bucket),
+		objects: make(map[bson.ObjectId]*Object),
+		apiKeys: make(map[bson.ObjectId]*APIKey),
+		users:   make(map[bson.ObjectId]*User),
+		roles:   make(map[bson.ObjectId]*Role),
+	}
+}
+
+func (idx *storeIndex) putBucket(b *Bucket) {
+	idx.buckets[b.ID] = copyBucket(b)
+}
+
+// insertAPIKey adds a new API key to the index, setting its ID and creation time if empty
+func (idx *storeIndex) insertAPIKey(k *APIKey) error {
+	if k.ID.Hex() == "" {
+		k.ID = bson.NewObjectId()
+	}
+	if _, ok := idx.apiKeys[k.ID]; ok {
+		return ErrDuplicateKey
+	}
+	if k.Created.IsZero() {
+		k.Created = uploadDate()
+	}
+	idx.apiKeys[k.ID] = copyAPIKey(k)
+	return nil
+}
+
+// findAPIKeys lists the API keys of an owner, or every key if the owner is empty, newest first
+func (idx *storeIndex) findAPIKeys(owner string) []*APIKey {
+	keys := []*APIKey{}
+	for _, k := range idx.apiKeys {
+		if owner == "" || k.Owner == owner {
+			keys = append(keys, copyAPIKey(k))
+		}
+	}
+	sort.Sort(newestAPIKeysFirst(keys))
+	return keys
+}
+
+// insertUser adds a new user to the index, setting its ID and creation time if empty
+func (idx *storeIndex) insertUser(u *User) error {
+	if u.ID.Hex() == "" {
+		u.ID = bson.NewObjectId()
+	}
+	if _, ok := idx.users[u.ID]; ok {
+		return ErrDuplicateKey
+	}
+	if idx.userSubject(u.Subject) != nil {
+		return ErrDuplicateKey
+	}
+	if u.Created.IsZero() {
+		u.Created = uploadDate()
+	}
+	idx.users[u.ID] = copyUser(u)
+	return nil
+}
+
+// updateUser replaces a user of the index, keeping the subjects unique
+func (idx *storeIndex) updateUser(u *User) error {
+	if _, ok := idx.users[u.ID]; !ok {
+		return ErrNotFound
+	}
+	if other := idx.userSubject(u.Subject); other != nil && other.ID != u.ID {
+		return ErrDuplicateKey
+	}
+	idx.users[u.ID] = copyUser(u)
+	return nil
+}
+
+func (idx *storeIndex) userSubject(subject string) *User {
+	for _, u := range idx.users {
+		if u.Subject == subject {
+			return u
+		}
+	}
+	return nil
+}
+
+// findUsers lists every user, sorted by subject
+func (idx *storeIndex) findUsers() []*User {
+	users := []*User{}
+	for _, u := range idx.users {
+		users = append(users, copyUser(u))
+	}
+	sort.Sort(bySubject(users))
+	return users
+}
+
+// insertRole adds a new role to the index, setting its ID and creation time if empty
+func (idx *storeIndex) insertRole(r *Role) error {
+	if r.ID.Hex() == "" {
+		r.ID = bson.NewObjectId()
+	}
+	if _, ok := idx.roles[r.ID]; ok {
+		return ErrDuplicateKey
+	}
+	if idx.roleName(r.Name) != nil {
+		return ErrDuplicateKey
+	}
+	if r.Created.IsZero() {
+		r.Created = uploadDate()
+	}
+	idx.roles[r.ID] = copyRole(r)
+	return nil
+}
+
+// updateRole replaces a role of the index, keeping the names unique
+func (idx *storeIndex) updateRole(r *Role) error {
+	if _, ok := idx.roles[r.ID]; !ok {
+		return ErrNotFound
+	}
+	if other := idx.roleName(r.Name); other != nil && other.ID != r.ID {
+		return ErrDuplicateKey
+	}
+	idx.roles[r.ID] = copyRole(r)
+	return nil
+}
+
+func (idx *storeIndex) roleName(name string) *Role {
+	for _, r := range idx.roles {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// findRoles lists every role, sorted by name
+func (idx *storeIndex) findRoles() []*Role {
+	roles := []*Role{}
+	for _, r := range idx.roles {
+		roles = append(roles, copyRole(r))
+	}
+	sort.Sort(rolesByName(roles))
+	return roles
+}
+
+func (idx *storeIndex) putObject(o *Object) {
+	if o.Metadata == nil {
+		o.Metadata = &ObjectMetadata{}
+	}
+	idx.objects[o.ID] = copyObject(o)
+}
+
+// addStats adds or subtracts (with a negative sign) the object to the statistics of its bucket,
+// returning the updated bucket, or nil if it is not in the index
+func (idx *storeIndex) addStats(o *Object, sign int) *Bucket {
+	b, ok := idx.buckets[o.Metadata.BucketID]
+	if !ok {
+		return nil
+	}
+	b.Objects += sign
+	b.Size += int64(sign) * o.Size
+	return b
+}
+
+// recomputeStats counts the objects of a bucket and their total size, returning the updated bucket
+func (idx *storeIndex) recomputeStats(bucketID bson.ObjectId) (*Bucket, error) {
+	b, ok := idx.buckets[bucketID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	b.Objects, b.Size = 0, 0
+	for _, o := range idx.objects {
+		if o.Metadata.BucketID == bucketID {
+			b.Objects++
+			b.Size += o.Size
+		}
+	}
+	return b, nil
+}
+
+// keepStats sets the statistics of the given bucket to the ones in the index
+func (idx *storeIndex) keepStats(b *Bucket) {
+	if stored, ok := idx.buckets[b.ID]; ok {
+		b.Objects, b.Size = stored.Objects, stored.Size
+	}
+}
+
+func (idx *storeIndex) bucketName(name string) *Bucket {
+	for _, b := range idx.buckets {
+		if b.Name == name {
+			return b
+		}
+	}
+	return nil
+}
+
+// findBuckets returns a page of copies of the buckets, sorted by name
+func (idx *storeIndex) findBuckets(opts BucketListOptions) (*BucketList, error) {
+	after, err := opts.after()
+	if err != nil {
+		return nil, err
+	}
+	bl := &BucketList{buckets: []*Bucket{}}
+	for _, b := range idx.buckets {
+		if b.Name > after && opts.match(b) {
+			bl.buckets = append(bl.buckets, copyBucket(b))
+		}
+	}
+	sort.Sort(byName(bl.buckets))
+	bl.paginate(opts.Limit)
+	return bl, nil
+}
+
+// list returns copies of the objects matching the filter, newest first
+func (idx *storeIndex) list(match func(o *Object) bool) *ObjectList {
+	fl, _ := idx.find(match, ListOptions{})
+	return fl
+}
+
+// find returns a page of copies of the objects matching the filter
+func (idx *storeIndex) find(match func(o *Object) bool, opts ListOptions) (*ObjectList, error) {
+	asc, err := opts.ascending()
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := opts.cursor()
+	if err != nil {
+		return nil, err
+	}
+	fl := &ObjectList{objects: []*Object{}}
+	prefixes := make(map[string]bool)
+	for _, o := range idx.objects {
+		if !match(o) || !strings.HasPrefix(o.Name, opts.Prefix) {
+			continue
+		}
+		if prefix := opts.commonPrefix(o.Name); prefix != "" {
+			prefixes[prefix] = true
+			continue
+		}
+		if cursor == nil || !cursor.before(o, asc) {
+			fl.objects = append(fl.objects, copyObject(o))
+		}
+	}
+	if cursor == nil {
+		for prefix := range prefixes {
+			fl.prefixes = append(fl.prefixes, prefix)
+		}
+		sort.Strings(fl.prefixes)
+	}
+	if asc {
+		sort.Sort(sort.Reverse(newestFirst(fl.objects)))
+	} else {
+		sort.Sort(newestFirst(fl.objects))
+	}
+	fl.paginate(opts.Limit)
+	return fl, nil
+}
+
+// newestNamed returns a copy of the newest object with the given name in a bucket, or nil if there is none
+func (idx *storeIndex) newestNamed(name string, bucketID bson.ObjectId) *Object {
+	fl := idx.list(func(o *Object) bool { return o.Name == name && o.Metadata.BucketID == bucketID })
+	if len(fl.objects) == 0 {
+		return nil
+	}
+	return fl.objects[0]
+}
+
+// uploadDate returns the current time with the millisecond precision of GridFS upload dates
+func uploadDate() time.Time {
+	return time.Now().Truncate(time.Millisecond)
+}
+
+// newestFirst sorts objects by descending upload date, as the GridFS driver does
+type newestFirst []*Object
+
+func (s newestFirst) Len() int      { return len(s) }
+func (s newestFirst) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s newestFirst) Less(i, j int) bool {
+	if s[i].UploadDate.Equal(s[j].UploadDate) {
+		return s[i].ID > s[j].ID
+	}
+	return s[i].UploadDate.After(s[j].UploadDate)
+}
+
+// byName sorts buckets by name
+type byName []*Bucket
+
+func (s byName) Len() int           { return len(s) }
+func (s byName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+type newestAPIKeysFirst []*APIKey
+
+func (s newestAPIKeysFirst) Len() int      { return len(s) }
+func (s newestAPIKeysFirst) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s newestAPIKeysFirst) Less(i, j int) bool {
+	if !s[i].Created.Equal(s[j].Created) {
+		return s[i].Created.After(s[j].Created)
+	}
+	return s[i].ID > s[j].ID
+}
+
+type bySubject []*User
+
+func (s bySubject) Len() int           { return len(s) }
+func (s bySubject) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s bySubject) Less(i, j int) bool { return s[i].Subject < s[j].Subject }
+
+type rolesByName []*Role
+
+func (s rolesByName) Len() int           { return len(s) }
+func (s rolesByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s rolesByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+func copyUser(u *User) *User {
+	c := *u
+	c.Roles = append([]string(nil), u.Roles...)
+	c.Groups = append([]string(nil), u.Groups...)
+	c.Buckets = append([]string(nil), u.Buckets...)
+	return &c
+}
+
+func copyRole(r *Role) *Role {
+	c := *r
+	return &c
+}
+
+func copyAPIKey(k *APIKey) *APIKey {
+	c := *k
+	return &c
+}
+
+func copyBucket(b *Bucket) *Bucket {
+	c := *b
+	return &c
+}
+
+func copyObject(o *Object) *Object {
+	c := *o
+	c.file = nil
+	if o.Metadata != nil {
+		meta := *o.Metadata
+		c.Metadata = &meta
+	}
+	return &c
+}
@@ -0,0 +1,311 @@
+package goose
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// MaxPartNumber is the highest number of a part in a multipart upload
+const MaxPartNumber = 10000
+
+var (
+	// ErrInvalidPartNumber is returned when uploading a part numbered out of the 1 to MaxPartNumber range
+	ErrInvalidPartNumber = fmt.Errorf("invalid part number, use 1 to %d", MaxPartNumber)
+	// ErrInvalidPart is returned when completing a multipart upload with a part that was not uploaded,
+	// or whose checksum does not match
+	ErrInvalidPart = errors.New("a part has not been uploaded or its checksum does not match")
+	// ErrInvalidPartOrder is returned when completing a multipart upload with parts not in ascending order
+	ErrInvalidPartOrder = errors.New("the parts must be listed in ascending order")
+)
+
+// MultipartUpload is an object being uploaded in parts, which can be sent concurrently and in any order.
+// Once every part is uploaded, they are joined into the object
+type MultipartUpload struct {
+	ID          string         `json:"id"`
+	BucketID    bson.ObjectId  `json:"bucketId"`
+	Name        string         `json:"name"`
+	ContentType string         `json:"contentType"`
+	Metadata    ObjectMetadata `json:"metadata"`
+	// Owner is the subject of the user who initiated the upload
+	Owner   string    `json:"owner,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// Part is an uploaded part of a multipart upload
+type Part struct {
+	Number int    `json:"number"`
+	Size   int64  `json:"size"`
+	MD5    string `json:"md5"`
+}
+
+// MultipartStore keeps the parts of the multipart uploads in a directory until they are complete. Every server
+// handling the parts of an upload must share the directory
+type MultipartStore struct {
+	dir string
+}
+
+// NewMultipartStore returns a multipart upload store keeping the parts in the given directory, which is created
+// if needed
+func NewMultipartStore(dir string) (*MultipartStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &MultipartStore{dir: dir}, nil
+}
+
+// path returns the path of the directory of an upload, or ErrNotFound if the ID is not valid
+func (s *MultipartStore) path(ID string) (string, error) {
+	if !ValidObjectID(ID) {
+		return "", ErrNotFound
+	}
+	return filepath.Join(s.dir, ID), nil
+}
+
+// Initiate starts a multipart upload, setting its ID
+func (s *MultipartStore) Initiate(u *MultipartUpload) error {
+	u.ID = bson.NewObjectId().Hex()
+	if u.Created.IsZero() {
+		u.Created = time.Now()
+	}
+	dir := filepath.Join(s.dir, u.ID)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "upload.json"), data, 0644)
+}
+
+// Find returns the multipart upload with the given ID
+func (s *MultipartStore) Find(ID string) (*MultipartUpload, error) {
+	dir, err := s.path(ID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "upload.json"))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	u := &MultipartUpload{}
+	return u, json.Unmarshal(data, u)
+}
+
+// PutPart stores a part of a multipart upload with the data read from r, replacing the part with the same number
+func (s *MultipartStore) PutPart(ID string, number int, r io.Reader) (*Part, error) {
+	if number < 1 || number > MaxPartNumber {
+		return nil, ErrInvalidPartNumber
+	}
+	dir, err := s.path(ID)
+	if err != nil {
+		return nil, err
+	}
+	f, err := ioutil.TempFile(dir, "part-")
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	h := md5.New()
+	// The checksum is written over the blank header once the data is read
+	_, err = f.Write(make([]byte, md5.Size))
+	var size int64
+	if err == nil {
+		size, err = io.Copy(f, io.TeeReader(r, h))
+	}
+	if err == nil {
+		_, err = f.WriteAt(h.Sum(nil), 0)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+	// The data and checksum share a single file, so the rename replaces the part atomically, the last one
+	// uploaded winning when the same part is sent twice at once
+	if err = os.Rename(f.Name(), filepath.Join(dir, partFileName(number))); err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &Part{Number: number, Size: size, MD5: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// partFileName returns the name of the file of a part. The file holds the MD5 checksum of the part, followed
+// by its data
+func partFileName(number int) string {
+	return fmt.Sprintf("%05d.part", number)
+}
+
+// openPart opens the file of a part, returning it positioned at the start of the data
+func openPart(dir string, number int) (*Part, *os.File, error) {
+	f, err := os.Open(filepath.Join(dir, partFileName(number)))
+	if err != nil {
+		return nil, nil, err
+	}
+	sum := make([]byte, md5.Size)
+	_, err = io.ReadFull(f, sum)
+	var info os.FileInfo
+	if err == nil {
+		info, err = f.Stat()
+	}
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return &Part{Number: number, Size: info.Size() - md5.Size, MD5: hex.EncodeToString(sum)}, f, nil
+}
+
+// Parts lists the uploaded parts of a multipart upload, by number
+func (s *MultipartStore) Parts(ID string) ([]*Part, error) {
+	dir, err := s.path(ID)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	parts := []*Part{}
+	for _, info := range infos {
+		name := strings.TrimSuffix(info.Name(), ".part")
+		number, err := strconv.Atoi(name)
+		if err != nil || name == info.Name() {
+			continue
+		}
+		part, f, err := openPart(dir, number)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		f.Close()
+		parts = append(parts, part)
+	}
+	sort.Sort(partsByNumber(parts))
+	return parts, nil
+}
+
+type partsByNumber []*Part
+
+func (p partsByNumber) Len() int           { return len(p) }
+func (p partsByNumber) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p partsByNumber) Less(i, j int) bool { return p[i].Number < p[j].Number }
+
+// Open returns the data of the object made of the given parts of a multipart upload, along with its size.
+// The parts must be listed in ascending order, and their checksums, if set, must match the uploaded ones.
+// IMPORTANT: close the data when no longer needed
+func (s *MultipartStore) Open(ID string, parts []Part) (io.ReadCloser, int64, error) {
+	if len(parts) == 0 {
+		return nil, 0, ErrInvalidPart
+	}
+	dir, err := s.path(ID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err = os.Stat(dir); os.IsNotExist(err) {
+		return nil, 0, ErrNotFound
+	}
+	data := &partsReader{}
+	var size int64
+	for i, p := range parts {
+		if i > 0 && p.Number <= parts[i-1].Number {
+			data.Close()
+			return nil, 0, ErrInvalidPartOrder
+		}
+		// The checksum is checked against the file being read, in case the part is replaced meanwhile
+		stored, f, err := openPart(dir, p.Number)
+		if err == nil && p.MD5 != "" && p.MD5 != stored.MD5 {
+			f.Close()
+			err = ErrInvalidPart
+		}
+		if err != nil {
+			data.Close()
+			if os.IsNotExist(err) {
+				err = ErrInvalidPart
+			}
+			return nil, 0, err
+		}
+		data.files = append(data.files, f)
+		size += stored.Size
+	}
+	readers := make([]io.Reader, len(data.files))
+	for i, f := range data.files {
+		readers[i] = f
+	}
+	data.Reader = io.MultiReader(readers...)
+	return data, size, nil
+}
+
+// partsReader reads the files of the parts one after another
+type partsReader struct {
+	io.Reader
+	files []*os.File
+}
+
+func (pr *partsReader) Close() error {
+	var err error
+	for _, f := range pr.files {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Remove deletes a multipart upload along with its parts, once completed or aborted
+func (s *MultipartStore) Remove(ID string) error {
+	dir, err := s.path(ID)
+	if err != nil {
+		return err
+	}
+	if _, err = os.Stat(dir); os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return os.RemoveAll(dir)
+}
+
+// Expire removes the multipart uploads started longer than maxAge ago, returning how many were removed
+func (s *MultipartStore) Expire(maxAge time.Duration) (int, error) {
+	infos, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, info := range infos {
+		if !info.IsDir() || !ValidObjectID(info.Name()) {
+			continue
+		}
+		if time.Since(bson.ObjectIdHex(info.Name()).Time()) < maxAge {
+			continue
+		}
+		if err = os.RemoveAll(filepath.Join(s.dir, info.Name())); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,75 @@
+package goose
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMultipartConcurrentParts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goose-multipart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := NewMultipartStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &MultipartUpload{Name: "/a.txt"}
+	if err = s.Initiate(u); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every upload of the same part replaces the others whole, whatever the order they finish in
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := s.PutPart(u.ID, 1, strings.NewReader(strings.Repeat(string(rune('a'+i)), 1000+i))); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if _, err = s.PutPart(u.ID, 2, strings.NewReader("end")); err != nil {
+		t.Fatal(err)
+	}
+
+	parts, err := s.Parts(u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 || parts[0].Number != 1 || parts[1].Number != 2 {
+		t.Fatalf("expected parts 1 and 2, got %+v", parts)
+	}
+	data, size, err := s.Open(u.ID, []Part{{Number: 1, MD5: parts[0].MD5}, {Number: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer data.Close()
+	b, err := ioutil.ReadAll(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part := b[:len(b)-3]
+	sum := md5.Sum(part)
+	if int64(len(b)) != size || int64(len(part)) != parts[0].Size || hex.EncodeToString(sum[:]) != parts[0].MD5 {
+		t.Errorf("the part data does not match its size %d and checksum %s", parts[0].Size, parts[0].MD5)
+	}
+	if strings.Trim(string(part), string(part[:1])) != "" || string(b[len(b)-3:]) != "end" {
+		t.Error("the part data is mixed up")
+	}
+
+	if _, _, err = s.Open(u.ID, []Part{{Number: 1, MD5: "bad"}}); err != ErrInvalidPart {
+		t.Errorf("expected ErrInvalidPart for a wrong checksum, got %v", err)
+	}
+	if _, _, err = s.Open(u.ID, []Part{{Number: 3}}); err != ErrInvalidPart {
+		t.Errorf("expected ErrInvalidPart for a missing part, got %v", err)
+	}
+}
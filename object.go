@@ -1,16 +1,16 @@
 package goose
 
 import (
-	"gopkg.in/mgo.v2"
-	"gopkg.in/mgo.v2/bson"
-	"io"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
-)
 
-func init() {
-	RegisterDBInitTask(func(db *DBConn) error { return NewObjectRepo(db).Init() })
-}
+	"gopkg.in/mgo.v2/bson"
+)
 
+// Object represents a stored file, along with its metadata
 type Object struct {
 	ID          bson.ObjectId   `json:"id"`
 	UploadDate  time.Time       `json:"uploadDate"`
@@ -19,177 +19,191 @@ type Object struct {
 	Name        string          `json:"name"`
 	ContentType string          `json:"contentType"`
 	Metadata    *ObjectMetadata `json:"metadata"`
-	gf          *mgo.GridFile
-}
-
-func newObjectFromGridFile(f *mgo.GridFile, fill bool) *Object {
-	obj := &Object{gf: f}
-	if fill {
-		obj.FillData()
-	}
-	return obj
+	file        ObjectFile
 }
 
+// GetID returns the ID of the object
 func (o *Object) GetID() bson.ObjectId {
-	return o.gf.Id().(bson.ObjectId)
+	return o.ID
 }
 
-func (o *Object) GridFile() *mgo.GridFile {
-	return o.gf
+// File returns the data stream of the object, or nil if it has not been opened for reading
+func (o *Object) File() ObjectFile {
+	return o.file
 }
 
-func (o *Object) FillData() {
-	o.ID = o.gf.Id().(bson.ObjectId)
-	o.UploadDate = o.gf.UploadDate()
-	o.Size = o.gf.Size()
-	o.MD5 = o.gf.MD5()
-	o.Name = o.gf.Name()
-	o.ContentType = o.gf.ContentType()
-	if o.Metadata == nil {
-		o.Metadata = &ObjectMetadata{}
-		o.gf.GetMeta(&o.Metadata)
+// Close closes the data stream of the object, if any
+func (o *Object) Close() error {
+	if o == nil || o.file == nil {
+		return nil
 	}
+	return o.file.Close()
 }
 
+// ObjectList holds the objects returned by a listing operation
 type ObjectList struct {
-	objects []*Object
-	iter    *mgo.Iter
+	objects  []*Object
+	prefixes []string
+	next     string
 }
 
+// Objects returns the objects in the list
 func (fl *ObjectList) Objects() []*Object {
+	if fl == nil || fl.objects == nil {
+		return []*Object{}
+	}
 	return fl.objects
 }
 
-func (fl *ObjectList) Close() error {
-	if fl == nil || fl.iter == nil {
+// Prefixes returns the common prefixes of a delimited listing, sorted by name
+func (fl *ObjectList) Prefixes() []string {
+	if fl == nil {
 		return nil
 	}
-	return fl.iter.Close()
+	return fl.prefixes
 }
 
-type ObjectMetadata struct {
-	BucketID    bson.ObjectId          `bson:"bucketId" json:"bucketId,omitempty"`
-	UploaderID  bson.ObjectId          `bson:"uploaderId,omitempty" json:"uploaderId,omitempty"`
-	Title       string                 `bson:"title,omitempty" json:"title"`
-	Description string                 `bson:"description,omitempty" json:"description"`
-	Tags        []string               `bson:"tags,omitempty" json:"tags"`
-	Custom      map[string]interface{} `bson:"custom,omitempty" json:"custom"`
-}
-
-type objectRepo struct {
-	gfs *mgo.GridFS
-	db  *DBConn
-}
-
-func NewObjectRepo(db *DBConn) *objectRepo {
-	return &objectRepo{gfs: db.GridFS("fs"), db: db}
-}
-
-func (or *objectRepo) Init() error {
-	index := mgo.Index{
-		Key:        []string{"filename", "metadata.bucketId"},
-		Unique:     false,
-		Background: false,
-		Sparse:     false,
+// Next returns the cursor to request the following page of the listing, or an empty string if this is the last one
+func (fl *ObjectList) Next() string {
+	if fl == nil {
+		return ""
 	}
-	return or.gfs.Files.EnsureIndex(index)
+	return fl.next
 }
 
-func (or *objectRepo) create(name, ctype string, metadata *ObjectMetadata) (*Object, error) {
-	gObject, err := or.gfs.Create(name)
-	if err != nil {
-		return nil, err
-	}
-	object := &Object{
-		gf: gObject,
-	}
-	object.gf.SetName(name)
-	if ctype != "" {
-		object.gf.SetContentType(ctype)
+// paginate trims the list to the given limit, setting the cursor for the next page if there were more objects
+func (fl *ObjectList) paginate(limit int) {
+	if limit <= 0 || len(fl.objects) <= limit {
+		return
 	}
-	if metadata != nil {
-		object.gf.SetMeta(metadata)
-	}
-	return object, nil
+	fl.objects = fl.objects[:limit]
+	fl.next = newListCursor(fl.objects[limit-1]).String()
 }
 
-func (or *objectRepo) Create(r io.Reader, name, ctype string, metadata *ObjectMetadata) (*Object, error) {
-	object, err := or.create(name, ctype, metadata)
-	if err != nil {
-		return object, err
+// Close closes the data streams of the listed objects, if any
+func (fl *ObjectList) Close() error {
+	if fl == nil {
+		return nil
 	}
-	defer object.gf.Close()
-
-	_, err = io.Copy(object.gf, r)
-	object.FillData()
-	return object, err
+	var err error
+	for _, o := range fl.objects {
+		if cerr := o.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
 }
 
-func (r *objectRepo) UpdateMetada(ID, name string, metadata ObjectMetadata) error {
-	return r.gfs.Files.Update(bson.M{"_id": bson.ObjectIdHex(ID)}, bson.M{"$set": bson.M{"metadata": metadata, "objectname": name}})
-}
+type ObjectMetadata struct {
+	BucketID    bson.ObjectId `bson:"bucketId" json:"bucketId,omitempty"`
+	UploaderID  bson.ObjectId `bson:"uploaderId,omitempty" json:"uploaderId,omitempty"`
+	Title       string        `bson:"title,omitempty" json:"title"`
+	Description string        `bson:"description,omitempty" json:"description"`
+	// CacheControl is the Cache-Control header sent when serving the object, overriding the bucket default
+	CacheControl string                 `bson:"cacheControl,omitempty" json:"cacheControl,omitempty"`
+	Tags         []string               `bson:"tags,omitempty" json:"tags"`
+	Custom       map[string]interface{} `bson:"custom,omitempty" json:"custom"`
+}
+
+const (
+	// SortNewestFirst lists objects by descending upload date
+	SortNewestFirst = "-uploadDate"
+	// SortOldestFirst lists objects by ascending upload date
+	SortOldestFirst = "uploadDate"
+)
 
-func (r *objectRepo) OpenId(ID string) (*Object, error) {
-	gridFile, err := r.gfs.OpenId(bson.ObjectIdHex(ID))
-	if err != nil {
-		return nil, err
+// ListOptions controls the objects returned by a listing operation
+type ListOptions struct {
+	// Limit is the maximum number of objects returned, 0 means no limit
+	Limit int
+	// Sort is the listing order, SortNewestFirst by default
+	Sort string
+	// Cursor resumes a previous listing, and must be the Next token of its last page
+	Cursor string
+	// Prefix restricts the listing to the objects whose name starts with it
+	Prefix string
+	// Delimiter groups the objects whose name contains it after the prefix into common prefixes, like directories.
+	// The common prefixes are returned along with the first page of the listing, instead of the grouped objects
+	Delimiter string
+}
+
+// commonPrefix returns the common prefix grouping the given object name in a delimited listing,
+// or an empty string if the object is listed by itself
+func (o ListOptions) commonPrefix(name string) string {
+	if o.Delimiter == "" || !strings.HasPrefix(name, o.Prefix) {
+		return ""
 	}
-	return newObjectFromGridFile(gridFile, true), nil
-}
-
-func (r *objectRepo) Open(filename string) (*Object, error) {
-	gridFile, err := r.gfs.Open(filename)
-	if err != nil {
-		return nil, err
+	rest := name[len(o.Prefix):]
+	if i := strings.Index(rest, o.Delimiter); i >= 0 {
+		return o.Prefix + rest[:i+len(o.Delimiter)]
 	}
-	return newObjectFromGridFile(gridFile, true), nil
+	return ""
 }
 
-func (r *objectRepo) OpenFromBucket(filename string, bucketID bson.ObjectId) (*Object, error) {
-	iter := r.gfs.Find(bson.M{"filename": filename, "metadata.bucketId": bucketID}).Sort("-uploadDate").Iter()
-	return r.iterToObject(iter)
-}
-
-func (r *objectRepo) DeleteId(ID string) error {
-	return r.gfs.RemoveId(bson.ObjectIdHex(ID))
+// ascending reports whether the objects must be listed in ascending upload date order
+func (o ListOptions) ascending() (bool, error) {
+	switch o.Sort {
+	case "", SortNewestFirst:
+		return false, nil
+	case SortOldestFirst:
+		return true, nil
+	}
+	return false, ErrInvalidSort
 }
 
-func (r *objectRepo) iterToObject(iter *mgo.Iter) (*Object, error) {
-	var f *mgo.GridFile
-	if r.gfs.OpenNext(iter, &f) {
-		return newObjectFromGridFile(f, true), nil
+// cursor decodes the listing cursor, returning nil if the listing starts from the beginning
+func (o ListOptions) cursor() (*listCursor, error) {
+	if o.Cursor == "" {
+		return nil, nil
 	}
-	return nil, mgo.ErrNotFound
+	return parseListCursor(o.Cursor)
 }
 
-func (r *objectRepo) iterToObjectList(iter *mgo.Iter) *ObjectList {
-	fl := ObjectList{iter: iter}
-
-	var f *mgo.GridFile
-	for r.gfs.OpenNext(iter, &f) {
-		fl.objects = append(fl.objects, newObjectFromGridFile(f, true))
-	}
-	return &fl
+// listCursor points to the last object of a listing page
+type listCursor struct {
+	UploadDate time.Time
+	ID         bson.ObjectId
 }
 
-func (r *objectRepo) All() (*ObjectList, error) {
-	where := bson.M{}
-	iter := r.gfs.Find(where).Sort("-uploadDate").Iter()
-	return r.iterToObjectList(iter), nil
+func newListCursor(o *Object) *listCursor {
+	return &listCursor{UploadDate: o.UploadDate, ID: o.ID}
 }
 
-func (r *objectRepo) FindByBucket(bucketID bson.ObjectId, skip, limit int) (*ObjectList, error) {
-	where := bson.M{"metadata.bucketId": bucketID}
-	iter := r.gfs.Find(where).Sort("-uploadDate").Iter()
-	return r.iterToObjectList(iter), nil
+// String returns the cursor encoded as an opaque token
+func (c *listCursor) String() string {
+	ms := c.UploadDate.UnixNano() / int64(time.Millisecond)
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d.%s", ms, c.ID.Hex())))
 }
 
-func (r *objectRepo) FindByIds(ids []string) (*ObjectList, error) {
-	var objIds []bson.ObjectId
-	for _, id := range ids {
-		objIds = append(objIds, bson.ObjectIdHex(id))
+func parseListCursor(token string) (*listCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), ".", 2)
+	if len(parts) != 2 || !ValidObjectID(parts[1]) {
+		return nil, ErrInvalidCursor
+	}
+	ms, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &listCursor{
+		UploadDate: time.Unix(0, ms*int64(time.Millisecond)),
+		ID:         bson.ObjectIdHex(parts[1]),
+	}, nil
+}
+
+// before reports whether the object goes before the cursor position in the given order
+func (c *listCursor) before(o *Object, ascending bool) bool {
+	if o.UploadDate.Equal(c.UploadDate) {
+		if ascending {
+			return o.ID <= c.ID
+		}
+		return o.ID >= c.ID
+	}
+	if ascending {
+		return o.UploadDate.Before(c.UploadDate)
 	}
-	where := bson.M{"_id": bson.M{"$in": objIds}}
-	iter := r.gfs.Find(where).Sort("-uploadDate").Iter()
-	return r.iterToObjectList(iter), nil
+	return o.UploadDate.After(c.UploadDate)
 }
@@ -0,0 +1,83 @@
+package goose
+
+import (
+	"errors"
+	"io"
+)
+
+var (
+	// ErrObjectTooLarge error is returned when uploading an object larger than allowed by the bucket quota
+	ErrObjectTooLarge = errors.New("the object exceeds the maximum size allowed in the bucket")
+	// ErrQuotaExceeded error is returned when uploading an object would exceed the total size or object count
+	// allowed by the bucket quota
+	ErrQuotaExceeded = errors.New("the bucket quota has been exceeded")
+)
+
+// BucketQuota limits the data stored in a bucket. Zero values mean no limit
+type BucketQuota struct {
+	// MaxSize is the maximum total size of the objects in the bucket, in bytes
+	MaxSize int64 `bson:"maxSize,omitempty" json:"maxSize"`
+	// MaxObjects is the maximum number of objects in the bucket
+	MaxObjects int `bson:"maxObjects,omitempty" json:"maxObjects"`
+	// MaxObjectSize is the maximum size of a single object, in bytes
+	MaxObjectSize int64 `bson:"maxObjectSize,omitempty" json:"maxObjectSize"`
+}
+
+// Enabled reports whether any limit is set
+func (q BucketQuota) Enabled() bool {
+	return q.MaxSize > 0 || q.MaxObjects > 0 || q.MaxObjectSize > 0
+}
+
+// CheckQuota checks whether a new object with the given size in bytes fits in the bucket, given the number of
+// objects and bytes it replaces. The quota is checked against the bucket statistics, so concurrent uploads may
+// exceed it slightly
+func (b *Bucket) CheckQuota(size int64, replacedObjects int, replacedSize int64) error {
+	q := b.Quota
+	if q.MaxObjectSize > 0 && size > q.MaxObjectSize {
+		return ErrObjectTooLarge
+	}
+	if q.MaxObjects > 0 && b.Objects-replacedObjects+1 > q.MaxObjects {
+		return ErrQuotaExceeded
+	}
+	if q.MaxSize > 0 && b.Size-replacedSize+size > q.MaxSize {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// QuotaReader wraps the data of an object being uploaded to the bucket, which fails with ErrObjectTooLarge or
+// ErrQuotaExceeded as soon as the data read exceeds the bucket quota, so the storage driver aborts the write.
+// If the size of the data is known in advance (-1 otherwise), the quota is checked before reading it.
+func (b *Bucket) QuotaReader(r io.Reader, size int64, replacedObjects int, replacedSize int64) (io.Reader, error) {
+	if size < 0 {
+		size = 0
+	}
+	if err := b.CheckQuota(size, replacedObjects, replacedSize); err != nil {
+		return nil, err
+	}
+	return &quotaReader{r: r, bucket: b, replacedObjects: replacedObjects, replacedSize: replacedSize}, nil
+}
+
+type quotaReader struct {
+	r               io.Reader
+	bucket          *Bucket
+	read            int64
+	replacedObjects int
+	replacedSize    int64
+}
+
+func (qr *quotaReader) Read(p []byte) (int, error) {
+	n, err := qr.r.Read(p)
+	qr.read += int64(n)
+	if qerr := qr.bucket.CheckQuota(qr.read, qr.replacedObjects, qr.replacedSize); qerr != nil {
+		return n, qerr
+	}
+	return n, err
+}
+
+// LimitReader wraps the data of an object being uploaded, which fails with ErrObjectTooLarge as soon as the data
+// read exceeds max bytes. If the size of the data is known in advance (-1 otherwise), it is checked before reading it.
+func LimitReader(r io.Reader, size, max int64) (io.Reader, error) {
+	b := &Bucket{Quota: BucketQuota{MaxObjectSize: max}}
+	return b.QuotaReader(r, size, 0, 0)
+}
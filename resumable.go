@@ -0,0 +1,251 @@
+package goose
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	// ErrOffsetMismatch is returned when appending data to a resumable upload at an offset other than the number
+	// of bytes already received
+	ErrOffsetMismatch = errors.New("the offset does not match the received data")
+	// ErrUploadTooLarge is returned when appending more data to a resumable upload than its declared length
+	ErrUploadTooLarge = errors.New("the data exceeds the length of the upload")
+	// ErrUploadLocked is returned when a resumable upload is already being written by another request
+	ErrUploadLocked = errors.New("the upload is locked by another request")
+)
+
+// ResumableUpload is an object uploaded as a sequence of chunks appended to its data, so an interrupted upload can
+// be resumed from the last byte received. Once all the data is received, it is stored as the object
+type ResumableUpload struct {
+	ID          string         `json:"id"`
+	BucketID    bson.ObjectId  `json:"bucketId"`
+	Name        string         `json:"name"`
+	ContentType string         `json:"contentType"`
+	Metadata    ObjectMetadata `json:"metadata"`
+	// Owner is the subject of the user who created the upload
+	Owner string `json:"owner,omitempty"`
+	// Length is the size of the object
+	Length  int64     `json:"length"`
+	Created time.Time `json:"created"`
+	// ObjectID is the ID of the stored object, once the upload is complete
+	ObjectID string `json:"objectId,omitempty"`
+	// Offset is the number of bytes received so far, and Updated the time the last ones were
+	Offset  int64     `json:"-"`
+	Updated time.Time `json:"-"`
+}
+
+// Complete tells whether all the data of the upload has been received
+func (u *ResumableUpload) Complete() bool {
+	return u.Offset == u.Length
+}
+
+// ResumableStore keeps the data of the resumable uploads in a directory until they are complete. Every server
+// handling the requests of an upload must share the directory
+type ResumableStore struct {
+	dir string
+}
+
+// NewResumableStore returns a resumable upload store keeping the data in the given directory, which is created
+// if needed
+func NewResumableStore(dir string) (*ResumableStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &ResumableStore{dir: dir}, nil
+}
+
+// path returns the path of the directory of an upload, or ErrNotFound if the ID is not valid
+func (s *ResumableStore) path(ID string) (string, error) {
+	if !ValidObjectID(ID) {
+		return "", ErrNotFound
+	}
+	return filepath.Join(s.dir, ID), nil
+}
+
+// Create starts a resumable upload with no data, setting its ID
+func (s *ResumableStore) Create(u *ResumableUpload) error {
+	u.ID = bson.NewObjectId().Hex()
+	if u.Created.IsZero() {
+		u.Created = time.Now()
+	}
+	dir := filepath.Join(s.dir, u.ID)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "data"), nil, 0644); err != nil {
+		return err
+	}
+	u.Offset, u.Updated = 0, u.Created
+	return s.save(u)
+}
+
+// save writes the record of an upload
+func (s *ResumableStore) save(u *ResumableUpload) error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(s.dir, u.ID, "upload.json"), data, 0644)
+}
+
+// Find returns the resumable upload with the given ID, with the number of bytes received so far
+func (s *ResumableStore) Find(ID string) (*ResumableUpload, error) {
+	dir, err := s.path(ID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "upload.json"))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	u := &ResumableUpload{}
+	if err = json.Unmarshal(data, u); err != nil {
+		return nil, err
+	}
+	// The data is removed once stored as the object
+	info, err := os.Stat(filepath.Join(dir, "data"))
+	if os.IsNotExist(err) && u.ObjectID != "" {
+		info, err = os.Stat(filepath.Join(dir, "upload.json"))
+		u.Offset = u.Length
+	} else if err == nil {
+		u.Offset = info.Size()
+	}
+	if err != nil {
+		return nil, err
+	}
+	u.Updated = info.ModTime()
+	return u, nil
+}
+
+// Lock reserves an upload for a request, so no other one, in this or another process sharing the directory, writes
+// to it at the same time. It returns ErrUploadLocked if it is already reserved, or else a function to release it.
+func (s *ResumableStore) Lock(ID string) (func(), error) {
+	dir, err := s.path(ID)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "lock"), os.O_RDONLY|os.O_CREATE, 0644)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The lock is held by the open file, so it is also released if the process dies
+	if err = lockFile(f, true, false); err != nil {
+		f.Close()
+		if err == errFileLocked {
+			return nil, ErrUploadLocked
+		}
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// Append adds the data read from r to a resumable upload, returning the new offset. The given offset must be the
+// number of bytes already received, and the data can not exceed the length of the upload. If reading r fails, the
+// data read until then is kept, so the upload can be resumed from there.
+// IMPORTANT: lock the upload while appending
+func (s *ResumableStore) Append(ID string, offset int64, r io.Reader) (int64, error) {
+	u, err := s.Find(ID)
+	if err != nil {
+		return 0, err
+	}
+	if u.ObjectID != "" || offset != u.Offset {
+		return u.Offset, ErrOffsetMismatch
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, ID, "data"), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return offset, err
+	}
+	// One byte more than the upload is missing is read, which means the data is longer than it and is rejected
+	// as a whole
+	remaining := u.Length - offset
+	n, err := io.Copy(f, io.LimitReader(r, remaining+1))
+	if n > remaining {
+		n, err = 0, ErrUploadTooLarge
+		f.Truncate(offset)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return offset + n, err
+}
+
+// Open returns the data received for a resumable upload.
+// IMPORTANT: close the data when no longer needed
+func (s *ResumableStore) Open(ID string) (io.ReadCloser, error) {
+	dir, err := s.path(ID)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(dir, "data"))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Finish records the object stored with the data of a complete upload, and removes the data. The upload is kept
+// until it expires, so the clients that missed the response to the last request can find the object
+func (s *ResumableStore) Finish(u *ResumableUpload, objectID string) error {
+	u.ObjectID = objectID
+	if err := s.save(u); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(s.dir, u.ID, "data"))
+}
+
+// Remove deletes a resumable upload along with its data
+func (s *ResumableStore) Remove(ID string) error {
+	dir, err := s.path(ID)
+	if err != nil {
+		return err
+	}
+	if _, err = os.Stat(dir); os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return os.RemoveAll(dir)
+}
+
+// Expire removes the resumable uploads that received no data in longer than maxAge, returning how many were removed
+func (s *ResumableStore) Expire(maxAge time.Duration) (int, error) {
+	infos, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, info := range infos {
+		if !info.IsDir() || !ValidObjectID(info.Name()) {
+			continue
+		}
+		updated := info.ModTime()
+		if u, err := s.Find(info.Name()); err == nil {
+			updated = u.Updated
+		} else if err != ErrNotFound {
+			return removed, err
+		}
+		if time.Since(updated) < maxAge {
+			continue
+		}
+		if err = os.RemoveAll(filepath.Join(s.dir, info.Name())); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,111 @@
+package goose
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestResumableLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goose-resumable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Two stores on the same directory stand for two server processes
+	s1, err := NewResumableStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := NewResumableStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &ResumableUpload{Name: "/a.txt", Length: 3}
+	if err = s1.Create(u); err != nil {
+		t.Fatal(err)
+	}
+
+	unlock, err := s1.Lock(u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []*ResumableStore{s1, s2} {
+		if _, err = s.Lock(u.ID); err != ErrUploadLocked {
+			t.Errorf("expected ErrUploadLocked, got %v", err)
+		}
+	}
+	unlock()
+	unlock, err = s2.Lock(u.ID)
+	if err != nil {
+		t.Fatalf("expected the released upload to be locked again, got %v", err)
+	}
+	unlock()
+
+	for _, ID := range []string{"not-an-id", bson.NewObjectId().Hex()} {
+		if _, err = s1.Lock(ID); err != ErrNotFound {
+			t.Errorf("Lock(%q): expected ErrNotFound, got %v", ID, err)
+		}
+	}
+}
+
+// chunkReader returns its chunks one per read, an empty one meaning a read of no data and no error
+type chunkReader struct {
+	chunks []string
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.chunks[0])
+	if r.chunks[0] = r.chunks[0][n:]; r.chunks[0] == "" {
+		r.chunks = r.chunks[1:]
+	}
+	return n, nil
+}
+
+func TestResumableAppend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goose-resumable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := NewResumableStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &ResumableUpload{Name: "/a.txt", Length: 6}
+	if err = s.Create(u); err != nil {
+		t.Fatal(err)
+	}
+
+	offset, err := s.Append(u.ID, 0, strings.NewReader("abc"))
+	if err != nil || offset != 3 {
+		t.Fatalf("expected offset 3, got %d, %v", offset, err)
+	}
+	if _, err = s.Append(u.ID, 0, strings.NewReader("abc")); err != ErrOffsetMismatch {
+		t.Errorf("expected ErrOffsetMismatch, got %v", err)
+	}
+	// The empty read right after the missing data must not hide the rest
+	for _, r := range []io.Reader{strings.NewReader("defg"), &chunkReader{[]string{"def", "", "g"}}} {
+		if offset, err = s.Append(u.ID, 3, r); err != ErrUploadTooLarge || offset != 3 {
+			t.Errorf("expected ErrUploadTooLarge at offset 3, got %d, %v", offset, err)
+		}
+	}
+	if offset, err = s.Append(u.ID, 3, &chunkReader{[]string{"de", "", "f"}}); err != nil || offset != 6 {
+		t.Fatalf("expected offset 6, got %d, %v", offset, err)
+	}
+	found, err := s.Find(u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found.Complete() {
+		t.Errorf("expected the upload to be complete, got offset %d", found.Offset)
+	}
+}
@@ -0,0 +1,100 @@
+package goose
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func init() {
+	RegisterDBInitTask(func(db *DBConn) error { return NewRoleRepo(db).Init() })
+}
+
+// Role is a named set of users, given to them in their stored record or their tokens, that can be granted
+// permissions in the bucket ACLs. The admin role is built in and does not need to be stored
+type Role struct {
+	ID          bson.ObjectId `bson:"_id" json:"id"`
+	Name        string        `bson:"name" json:"name"`
+	Description string        `bson:"description,omitempty" json:"description,omitempty"`
+	Created     time.Time     `bson:"created" json:"created"`
+}
+
+// RoleStore is implemented by the storage drivers that persist roles
+type RoleStore interface {
+	// Insert stores a new role. ErrDuplicateKey is returned if the name is already taken
+	Insert(r *Role) error
+	// FindId returns the role with the given ID
+	FindId(ID string) (*Role, error)
+	// FindName returns the role with the given name
+	FindName(name string) (*Role, error)
+	// Update replaces the stored role with the given one
+	Update(r *Role) error
+	// DeleteId removes the role with the given ID
+	DeleteId(ID string) error
+	// Find lists every role, sorted by name
+	Find() ([]*Role, error)
+}
+
+type roleRepo struct {
+	db  *DBConn
+	col *mgo.Collection
+}
+
+func NewRoleRepo(db *DBConn) *roleRepo {
+	return &roleRepo{db: db, col: db.C("roles")}
+}
+
+func (r *roleRepo) Init() error {
+	return r.col.EnsureIndex(mgo.Index{Key: []string{"name"}, Unique: true})
+}
+
+func (r *roleRepo) Insert(role *Role) error {
+	if role.ID.Hex() == "" {
+		role.ID = bson.NewObjectId()
+	}
+	if role.Created.IsZero() {
+		role.Created = time.Now()
+	}
+	err := r.col.Insert(role)
+	if mgo.IsDup(err) {
+		return ErrDuplicateKey
+	}
+	return err
+}
+
+func (r *roleRepo) FindId(ID string) (*Role, error) {
+	role := &Role{}
+	if err := checkObjectId(ID); err != nil {
+		return role, err
+	}
+	err := r.col.FindId(bson.ObjectIdHex(ID)).One(role)
+	return role, err
+}
+
+func (r *roleRepo) FindName(name string) (*Role, error) {
+	role := &Role{}
+	err := r.col.Find(bson.M{"name": name}).One(role)
+	return role, err
+}
+
+func (r *roleRepo) Update(role *Role) error {
+	err := r.col.UpdateId(role.ID, role)
+	if mgo.IsDup(err) {
+		return ErrDuplicateKey
+	}
+	return err
+}
+
+func (r *roleRepo) DeleteId(ID string) error {
+	if err := checkObjectId(ID); err != nil {
+		return err
+	}
+	return r.col.RemoveId(bson.ObjectIdHex(ID))
+}
+
+func (r *roleRepo) Find() ([]*Role, error) {
+	roles := []*Role{}
+	err := r.col.Find(nil).Sort("name").All(&roles)
+	return roles, err
+}
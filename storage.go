@@ -0,0 +1,137 @@
+package goose
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/syb-devs/dockerlink"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ObjectFile is the data stream of a stored object
+type ObjectFile interface {
+	io.Reader
+	io.Seeker
+	io.Closer
+}
+
+// ObjectStore is implemented by the storage drivers that persist objects and their data
+type ObjectStore interface {
+	// Create stores a new object with the data read from r
+	Create(r io.Reader, name, ctype string, metadata *ObjectMetadata) (*Object, error)
+	// FindId returns the object with the given ID, without opening its data stream
+	FindId(ID string) (*Object, error)
+	// OpenId returns the object with the given ID, opened for reading. IMPORTANT: close the object when no longer needed
+	OpenId(ID string) (*Object, error)
+	// OpenFromBucket returns the newest object with the given name in a bucket, opened for reading.
+	// IMPORTANT: close the object when no longer needed
+	OpenFromBucket(name string, bucketID bson.ObjectId) (*Object, error)
+	// UpdateMetadata replaces the name and metadata of the object with the given ID
+	UpdateMetadata(ID, name string, metadata ObjectMetadata) error
+	// DeleteId removes the object with the given ID, along with its data
+	DeleteId(ID string) error
+	// FindByBucket lists a page of the objects stored in a bucket
+	FindByBucket(bucketID bson.ObjectId, opts ListOptions) (*ObjectList, error)
+	// FindVersions lists every stored version of the object with the given name in a bucket, newest first
+	FindVersions(bucketID bson.ObjectId, name string) (*ObjectList, error)
+	// FindByIds lists the objects with the given IDs, newest first
+	FindByIds(ids []string) (*ObjectList, error)
+	// RecomputeBucketStats counts the objects stored in a bucket and their total size, fixing the statistics
+	// that are maintained in the bucket when objects are created and deleted
+	RecomputeBucketStats(bucketID bson.ObjectId) error
+}
+
+// BucketStore is implemented by the storage drivers that persist buckets
+type BucketStore interface {
+	// Insert stores a new bucket. ErrDuplicateKey is returned if the bucket name is already taken
+	Insert(b *Bucket) error
+	// FindId returns the bucket with the given ID
+	FindId(ID string) (*Bucket, error)
+	// FindName returns the bucket with the given name
+	FindName(name string) (*Bucket, error)
+	// Update replaces the stored bucket with the given one, except for the object count and size statistics,
+	// which are maintained by the object store. The statistics of the given bucket are set to the stored ones
+	Update(b *Bucket) error
+	// DeleteId removes the bucket with the given ID
+	DeleteId(ID string) error
+	// Exists checks whether a bucket with the given name exists
+	Exists(name string) bool
+	// Find lists a page of the buckets, sorted by name
+	Find(opts BucketListOptions) (*BucketList, error)
+}
+
+// Storage is a session with a storage backend, giving access to its repositories
+type Storage interface {
+	// Buckets returns the bucket store of the backend
+	Buckets() BucketStore
+	// Objects returns the object store of the backend
+	Objects() ObjectStore
+	// APIKeys returns the API key store of the backend
+	APIKeys() APIKeyStore
+	// Users returns the user store of the backend
+	Users() UserStore
+	// Roles returns the role store of the backend
+	Roles() RoleStore
+	// Copy creates a new session with the backend. IMPORTANT: close the copied session when no longer needed
+	Copy() Storage
+	// Close releases the resources held by the session
+	Close()
+}
+
+var defaultStorage Storage
+
+// SetDefaultStorage sets the globally accessible storage backend
+func SetDefaultStorage(s Storage) {
+	defaultStorage = s
+}
+
+// DefaultStorage returns the global storage backend
+func DefaultStorage() Storage {
+	return defaultStorage
+}
+
+// StorageFromEnv sets up the storage backend selected with the STORAGE_DRIVER env var: gridfs (the default), with
+// the database set in DBNAME and found with MONGO_URL or a docker link, local, in the directory set in STORAGE_PATH,
+// or memory
+func StorageFromEnv() (Storage, error) {
+	switch driver := envDefault("STORAGE_DRIVER", "gridfs"); driver {
+	case "gridfs":
+		uri, err := mongoURIFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		db := NewDBConn(DBOptions{
+			Database:     envDefault("DBNAME", "goose"),
+			URL:          uri,
+			SetAsDefault: true,
+		})
+		return NewGridFSStorage(db), nil
+	case "local":
+		return NewLocalStorage(envDefault("STORAGE_PATH", "data"))
+	case "memory":
+		return NewMemoryStorage(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q, use gridfs, local or memory", driver)
+	}
+}
+
+// mongoURIFromEnv returns the MongoDB address set in MONGO_URL, or else the one of the docker link named after
+// DOCKER_MONGO_NAME (mongodb by default)
+func mongoURIFromEnv() (string, error) {
+	if uri := os.Getenv("MONGO_URL"); uri != "" {
+		return uri, nil
+	}
+	if link, err := dockerlink.GetLink(envDefault("DOCKER_MONGO_NAME", "mongodb"), 27017, "tcp"); err == nil {
+		return fmt.Sprintf("%s:%d", link.Address, link.Port), nil
+	}
+	return "", errors.New("mongodb connection not found, use MONGO_URL env var or a docker link with mongodb name")
+}
+
+func envDefault(key, defval string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return defval
+}
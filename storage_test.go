@@ -0,0 +1,210 @@
+package goose
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// testDrivers are the storage drivers checked by the driver tests. Each one returns a new empty storage and a
+// function to remove it. The gridfs driver is only checked when GOOSE_TEST_MONGO_URL is set
+var testDrivers = []struct {
+	name string
+	open func(t *testing.T) (Storage, func())
+}{
+	{"memory", func(t *testing.T) (Storage, func()) {
+		return NewMemoryStorage(), func() {}
+	}},
+	{"local", func(t *testing.T) (Storage, func()) {
+		dir, err := ioutil.TempDir("", "goose-local")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := NewLocalStorage(dir)
+		if err != nil {
+			os.RemoveAll(dir)
+			t.Fatal(err)
+		}
+		return s, func() { os.RemoveAll(dir) }
+	}},
+	{"gridfs", func(t *testing.T) (Storage, func()) {
+		url := os.Getenv("GOOSE_TEST_MONGO_URL")
+		if url == "" {
+			t.Skip("GOOSE_TEST_MONGO_URL not set")
+		}
+		db := NewDBConn(DBOptions{URL: url, Database: "goose_test_" + bson.NewObjectId().Hex()})
+		return NewGridFSStorage(db), func() {
+			db.DropDatabase()
+			db.Close()
+		}
+	}},
+}
+
+// forEachDriver runs a test against every storage driver
+func forEachDriver(t *testing.T, test func(t *testing.T, s Storage)) {
+	for _, d := range testDrivers {
+		d := d
+		t.Run(d.name, func(t *testing.T) {
+			s, remove := d.open(t)
+			defer remove()
+			test(t, s)
+		})
+	}
+}
+
+// createObjects stores count objects with the given name in a bucket
+func createObjects(t *testing.T, s Storage, bucketID bson.ObjectId, name string, count int) []*Object {
+	objects := make([]*Object, count)
+	for i := range objects {
+		o, err := s.Objects().Create(strings.NewReader("data"), name, "text/plain", &ObjectMetadata{BucketID: bucketID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		objects[i] = o
+	}
+	return objects
+}
+
+func TestStorageIDs(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, s Storage) {
+		b := &Bucket{Name: "ids"}
+		if err := s.Buckets().Insert(b); err != nil {
+			t.Fatal(err)
+		}
+		o := createObjects(t, s, b.ID, "/a.txt", 1)[0]
+		missing := bson.NewObjectId().Hex()
+
+		tests := []struct {
+			name string
+			call func(ID string) error
+		}{
+			{"Buckets.FindId", func(ID string) error { _, err := s.Buckets().FindId(ID); return err }},
+			{"Buckets.DeleteId", s.Buckets().DeleteId},
+			{"Objects.FindId", func(ID string) error { _, err := s.Objects().FindId(ID); return err }},
+			{"Objects.OpenId", func(ID string) error { _, err := s.Objects().OpenId(ID); return err }},
+			{"Objects.DeleteId", s.Objects().DeleteId},
+		}
+		for _, test := range tests {
+			for _, ID := range []string{"", "zz", "not-an-object-id", missing + "00"} {
+				if err := test.call(ID); err != ErrInvalidIDFormat {
+					t.Errorf("%s(%q): expected ErrInvalidIDFormat, got %v", test.name, ID, err)
+				}
+			}
+			if err := test.call(missing); err != ErrNotFound {
+				t.Errorf("%s(missing): expected ErrNotFound, got %v", test.name, err)
+			}
+		}
+
+		found, err := s.Objects().FindId(o.ID.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found.Name != "/a.txt" || found.Size != 4 || found.Metadata.BucketID != b.ID {
+			t.Errorf("unexpected object %+v", found)
+		}
+		if err = s.Objects().DeleteId(o.ID.Hex()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = s.Objects().FindId(o.ID.Hex()); err != ErrNotFound {
+			t.Errorf("expected ErrNotFound for the deleted object, got %v", err)
+		}
+		if err = s.Buckets().DeleteId(b.ID.Hex()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = s.Buckets().FindId(b.ID.Hex()); err != ErrNotFound {
+			t.Errorf("expected ErrNotFound for the deleted bucket, got %v", err)
+		}
+	})
+}
+
+func TestStorageFindName(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, s Storage) {
+		b := &Bucket{Name: "named"}
+		if err := s.Buckets().Insert(b); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Buckets().Insert(&Bucket{Name: "named"}); err != ErrDuplicateKey {
+			t.Errorf("expected ErrDuplicateKey, got %v", err)
+		}
+		found, err := s.Buckets().FindName("named")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found.ID != b.ID {
+			t.Errorf("expected bucket %s, got %s", b.ID.Hex(), found.ID.Hex())
+		}
+		if _, err = s.Buckets().FindName("missing"); err != ErrNotFound {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+		if !s.Buckets().Exists("named") || s.Buckets().Exists("missing") {
+			t.Error("Exists does not match FindName")
+		}
+
+		versions := createObjects(t, s, b.ID, "/v.txt", 3)
+		o, err := s.Objects().OpenFromBucket("/v.txt", b.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o.Close()
+		if o.ID != versions[2].ID {
+			t.Errorf("expected the newest version %s, got %s", versions[2].ID.Hex(), o.ID.Hex())
+		}
+		if _, err = s.Objects().OpenFromBucket("/missing.txt", b.ID); err != ErrNotFound {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestStoragePagination(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, s Storage) {
+		b := &Bucket{Name: "pages"}
+		if err := s.Buckets().Insert(b); err != nil {
+			t.Fatal(err)
+		}
+		other := &Bucket{Name: "other"}
+		if err := s.Buckets().Insert(other); err != nil {
+			t.Fatal(err)
+		}
+		createObjects(t, s, b.ID, "/f.txt", 7)
+		createObjects(t, s, other.ID, "/f.txt", 2)
+
+		for _, order := range []string{"", SortOldestFirst} {
+			seen := make(map[bson.ObjectId]bool)
+			opts := ListOptions{Limit: 3, Sort: order}
+			pages := 0
+			for {
+				list, err := s.Objects().FindByBucket(b.ID, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				pages++
+				for _, o := range list.Objects() {
+					if seen[o.ID] {
+						t.Errorf("sort %q: object %s listed twice", order, o.ID.Hex())
+					}
+					if o.Metadata.BucketID != b.ID {
+						t.Errorf("sort %q: object %s from another bucket", order, o.ID.Hex())
+					}
+					seen[o.ID] = true
+				}
+				if list.Next() == "" {
+					break
+				}
+				opts.Cursor = list.Next()
+			}
+			if len(seen) != 7 || pages != 3 {
+				t.Errorf("sort %q: expected 7 objects in 3 pages, got %d in %d", order, len(seen), pages)
+			}
+		}
+
+		if _, err := s.Objects().FindByBucket(b.ID, ListOptions{Cursor: "!!"}); err != ErrInvalidCursor {
+			t.Errorf("expected ErrInvalidCursor, got %v", err)
+		}
+		if _, err := s.Objects().FindByBucket(b.ID, ListOptions{Sort: "sideways"}); err != ErrInvalidSort {
+			t.Errorf("expected ErrInvalidSort, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package goose
+
+import "io"
+
+// PutObject stores a new object in a bucket with the data read from r, enforcing the bucket quota. If the size of
+// the data is known in advance (-1 otherwise), the quota is checked before reading it. In buckets without
+// versioning, the object replaces the existing ones with the same name
+func PutObject(repo ObjectStore, bucket *Bucket, r io.Reader, size int64, name, ctype string, meta *ObjectMetadata) (*Object, error) {
+	meta.BucketID = bucket.ID
+	data, err := limitUpload(repo, bucket, name, r, size)
+	if err != nil {
+		return nil, err
+	}
+	object, err := repo.Create(data, name, ctype, meta)
+	if err != nil {
+		return nil, err
+	}
+	if !bucket.Versioning {
+		if err = removeOlderVersions(repo, object); err != nil {
+			return nil, err
+		}
+	}
+	return object, nil
+}
+
+// limitUpload wraps the data of an object being uploaded, enforcing the bucket quota. In buckets without
+// versioning, the object replaces the existing ones with the same name, so they are not counted
+func limitUpload(repo ObjectStore, bucket *Bucket, name string, data io.Reader, size int64) (io.Reader, error) {
+	if !bucket.Quota.Enabled() {
+		return data, nil
+	}
+	var replacedObjects int
+	var replacedSize int64
+	if !bucket.Versioning {
+		versions, err := repo.FindVersions(bucket.ID, name)
+		if err != nil {
+			return nil, err
+		}
+		versions.Close()
+		for _, version := range versions.Objects() {
+			replacedObjects++
+			replacedSize += version.Size
+		}
+	}
+	return bucket.QuotaReader(data, size, replacedObjects, replacedSize)
+}
+
+// removeOlderVersions deletes the versions of an object that are older than the given one, so it replaces them
+// in buckets without versioning. Newer versions are kept, so concurrent uploads of the same name leave the last one.
+func removeOlderVersions(repo ObjectStore, object *Object) error {
+	versions, err := repo.FindVersions(object.Metadata.BucketID, object.Name)
+	if err != nil {
+		return err
+	}
+	defer versions.Close()
+
+	older := false
+	for _, version := range versions.Objects() {
+		if older {
+			if err = repo.DeleteId(version.ID.Hex()); err != nil && err != ErrNotFound {
+				return err
+			}
+		}
+		if version.ID == object.ID {
+			older = true
+		}
+	}
+	return nil
+}
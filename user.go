@@ -1,14 +1,233 @@
 package goose
 
-//TODO: basic ACL
-//TODO: retrieve the user from JWT auth in the request
+import (
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func init() {
+	RegisterDBInitTask(func(db *DBConn) error { return NewUserRepo(db).Init() })
+}
+
+// User is the user making a request. It is given by the claims of its token or by its API key, along with the roles,
+// groups and buckets of its stored record, if any
 type User struct {
+	ID bson.ObjectId `bson:"_id" json:"id"`
+	// Subject identifies the user, and is the subject of its tokens
+	Subject string `bson:"subject" json:"subject"`
+	Name    string `bson:"name,omitempty" json:"name,omitempty"`
+	Email   string `bson:"email,omitempty" json:"email,omitempty"`
+	// Roles are the roles granted to the user
+	Roles []string `bson:"roles,omitempty" json:"roles"`
+	// Groups are the groups the user is a member of
+	Groups []string `bson:"groups,omitempty" json:"groups"`
+	// Buckets are the names or IDs of the buckets the user can access. Empty means any bucket
+	Buckets []string `bson:"buckets,omitempty" json:"buckets"`
+	// Disabled users are refused authentication
+	Disabled bool      `bson:"disabled" json:"disabled"`
+	Created  time.Time `bson:"created" json:"created"`
+	// Scopes, if not nil, are the only operations allowed to the user, as given by its API key
+	Scopes []string `bson:"-" json:"-"`
+}
+
+// Merge adds the roles and groups of the stored record of the user. The buckets of the record apply only if
+// the user has no bucket restrictions of its own
+func (u *User) Merge(record *User) {
+	u.ID = record.ID
+	u.Name = record.Name
+	u.Email = record.Email
+	u.Disabled = record.Disabled
+	u.Created = record.Created
+	u.Roles = mergeNames(u.Roles, record.Roles)
+	u.Groups = mergeNames(u.Groups, record.Groups)
+	if len(u.Buckets) == 0 {
+		u.Buckets = record.Buckets
+	}
 }
 
+// mergeNames returns the names in a followed by those in b that are not in a
+func mergeNames(a, b []string) []string {
+	merged := append([]string{}, a...)
+	for _, name := range b {
+		if !containsName(merged, name) {
+			merged = append(merged, name)
+		}
+	}
+	return merged
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole tells whether the user has been granted the given role
+func (u *User) HasRole(role string) bool {
+	if u == nil {
+		return false
+	}
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// InGroup tells whether the user is a member of the given group
+func (u *User) InGroup(group string) bool {
+	return u != nil && containsName(u.Groups, group)
+}
+
+// HasScope tells whether the user is allowed the operations of the scope. Users authenticated without an API key
+// are allowed every scope
+func (u *User) HasScope(scope string) bool {
+	if u == nil {
+		return false
+	}
+	if u.Scopes == nil {
+		return true
+	}
+	for _, s := range u.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccessBucket tells whether the bucket is one of the buckets allowed to the user
+func (u *User) CanAccessBucket(bucket *Bucket) bool {
+	if u == nil {
+		return false
+	}
+	if len(u.Buckets) == 0 {
+		return true
+	}
+	for _, b := range u.Buckets {
+		if b == bucket.Name || b == bucket.ID.Hex() {
+			return true
+		}
+	}
+	return false
+}
+
+// Can tells whether the user is allowed to do an operation in a bucket. Users with the admin role are allowed
+// everything in the buckets they can access, and the rest as given by the bucket ACL
+func (u *User) Can(bucket *Bucket, perm Permission) bool {
+	if !u.CanAccessBucket(bucket) {
+		return false
+	}
+	if u.HasRole(RoleAdmin) {
+		return true
+	}
+	return bucket.ACL.Allows(u, perm)
+}
+
+// CanReadBucket tells whether the user is allowed to read a bucket and its objects
 func (u *User) CanReadBucket(bucket *Bucket) bool {
-	return true
+	return u.Can(bucket, PermRead)
 }
 
+// CanWriteBucket tells whether the user is allowed to upload and update objects in a bucket
 func (u *User) CanWriteBucket(bucket *Bucket) bool {
-	return true
+	return u.Can(bucket, PermWrite)
+}
+
+// CanDownload tells whether the user is allowed to download the objects of a bucket from the file server,
+// as given by the bucket visibility. The user is nil for anonymous requests
+func (u *User) CanDownload(bucket *Bucket) bool {
+	switch bucket.Visibility {
+	case VisibilityAuthenticated:
+		return u.CanAccessBucket(bucket)
+	case VisibilityPrivate:
+		return u.CanReadBucket(bucket)
+	default:
+		return true
+	}
+}
+
+// UserStore is implemented by the storage drivers that persist the user records
+type UserStore interface {
+	// Insert stores a new user. ErrDuplicateKey is returned if the subject is already taken
+	Insert(u *User) error
+	// FindId returns the user with the given ID
+	FindId(ID string) (*User, error)
+	// FindSubject returns the user with the given subject
+	FindSubject(subject string) (*User, error)
+	// Update replaces the stored user with the given one
+	Update(u *User) error
+	// DeleteId removes the user with the given ID
+	DeleteId(ID string) error
+	// Find lists every user, sorted by subject
+	Find() ([]*User, error)
+}
+
+type userRepo struct {
+	db  *DBConn
+	col *mgo.Collection
+}
+
+func NewUserRepo(db *DBConn) *userRepo {
+	return &userRepo{db: db, col: db.C("users")}
+}
+
+func (r *userRepo) Init() error {
+	return r.col.EnsureIndex(mgo.Index{Key: []string{"subject"}, Unique: true})
+}
+
+func (r *userRepo) Insert(u *User) error {
+	if u.ID.Hex() == "" {
+		u.ID = bson.NewObjectId()
+	}
+	if u.Created.IsZero() {
+		u.Created = time.Now()
+	}
+	err := r.col.Insert(u)
+	if mgo.IsDup(err) {
+		return ErrDuplicateKey
+	}
+	return err
+}
+
+func (r *userRepo) FindId(ID string) (*User, error) {
+	u := &User{}
+	if err := checkObjectId(ID); err != nil {
+		return u, err
+	}
+	err := r.col.FindId(bson.ObjectIdHex(ID)).One(u)
+	return u, err
+}
+
+func (r *userRepo) FindSubject(subject string) (*User, error) {
+	u := &User{}
+	err := r.col.Find(bson.M{"subject": subject}).One(u)
+	return u, err
+}
+
+func (r *userRepo) Update(u *User) error {
+	err := r.col.UpdateId(u.ID, u)
+	if mgo.IsDup(err) {
+		return ErrDuplicateKey
+	}
+	return err
+}
+
+func (r *userRepo) DeleteId(ID string) error {
+	if err := checkObjectId(ID); err != nil {
+		return err
+	}
+	return r.col.RemoveId(bson.ObjectIdHex(ID))
+}
+
+func (r *userRepo) Find() ([]*User, error) {
+	users := []*User{}
+	err := r.col.Find(nil).Sort("subject").All(&users)
+	return users, err
 }